@@ -0,0 +1,387 @@
+package gogorequest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// 请求认证接口, 在请求发送前添加认证信息
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// 需要根据401响应质询重新认证的认证方式, 如Digest认证
+type ChallengeAuthenticator interface {
+	Authenticator
+	// 根据401响应更新认证状态, 返回true时重新认证并再次发送请求
+	Challenge(req *http.Request, res *http.Response) (bool, error)
+}
+
+// 为引擎设置认证方式, 对引擎的所有请求生效
+func (this *mainEngine) SetAuthenticator(auth Authenticator) {
+	this.auth = auth
+}
+
+// 设置当前请求的认证方式, 覆盖引擎的认证方式
+func WithAuth(auth Authenticator) RequestOption {
+	return func(options *requestOptions) {
+		options.auth = auth
+		options.authSet = true
+	}
+}
+
+// 获取请求的认证方式, 请求设置的认证方式优先
+func (this *mainEngine) authenticator(request *Request) Authenticator {
+	if request.options != nil && request.options.authSet {
+		return request.options.auth
+	}
+	return this.auth
+}
+
+// 收到401响应时根据质询重新认证并再次发送请求, 请求体不能重复读取时返回原响应
+func (this *mainEngine) reauthenticate(client *http.Client, auth Authenticator, req *http.Request, res *http.Response) (*http.Response, error) {
+	challenger, ok := auth.(ChallengeAuthenticator)
+	if !ok || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return res, nil
+	}
+	retry, challengeErr := challenger.Challenge(req, res)
+	if challengeErr != nil {
+		res.Body.Close()
+		return nil, challengeErr
+	}
+	if !retry {
+		return res, nil
+	}
+	retryReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, getBodyErr := req.GetBody()
+		if getBodyErr != nil {
+			return res, nil
+		}
+		retryReq.Body = body
+	}
+	if authErr := auth.Authenticate(retryReq); authErr != nil {
+		res.Body.Close()
+		return nil, authErr
+	}
+	// 读完并关闭401响应, 以便复用连接
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64*1024))
+	res.Body.Close()
+	return client.Do(retryReq)
+}
+
+// Basic认证
+type basicAuth struct {
+	username string
+	password string
+}
+
+// 实例化Basic认证
+func NewBasicAuth(username string, password string) Authenticator {
+	return &basicAuth{username: username, password: password}
+}
+
+func (this *basicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(this.username, this.password)
+	return nil
+}
+
+// Bearer令牌认证
+type bearerAuth struct {
+	token string
+}
+
+// 实例化Bearer令牌认证, 请求头为Authorization: Bearer token
+func NewBearerAuth(token string) Authenticator {
+	return &bearerAuth{token: token}
+}
+
+func (this *bearerAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+this.token)
+	return nil
+}
+
+// API key的位置
+type APIKeyLocation int
+
+const (
+	APIKeyInHeader APIKeyLocation = iota // 请求头
+	APIKeyInQuery                        // URL参数
+)
+
+// API key认证
+type apiKeyAuth struct {
+	name     string
+	value    string
+	location APIKeyLocation
+}
+
+// 实例化API key认证, name为请求头或URL参数名
+func NewAPIKeyAuth(name string, value string, location APIKeyLocation) Authenticator {
+	return &apiKeyAuth{name: name, value: value, location: location}
+}
+
+func (this *apiKeyAuth) Authenticate(req *http.Request) error {
+	if this.location == APIKeyInQuery {
+		query := req.URL.Query()
+		query.Set(this.name, this.value)
+		req.URL.RawQuery = query.Encode()
+		return nil
+	}
+	req.Header.Set(this.name, this.value)
+	return nil
+}
+
+// Digest认证(RFC 7616), 收到401质询后计算认证信息, 之后的请求复用服务端的nonce
+type digestAuth struct {
+	username  string
+	password  string
+	lock      sync.Mutex
+	challenge *digestChallenge
+	nc        int64
+}
+
+// Digest认证质询
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // 选用的qop, 服务端未提供时为空
+	userhash  bool
+}
+
+// 实例化Digest认证, 支持MD5、SHA-256、SHA-512-256及其-sess算法, qop为auth或auth-int
+func NewDigestAuth(username string, password string) Authenticator {
+	return &digestAuth{username: username, password: password}
+}
+
+func (this *digestAuth) Authenticate(req *http.Request) error {
+	this.lock.Lock()
+	if this.challenge == nil {
+		this.lock.Unlock()
+		return nil
+	}
+	challenge := *this.challenge
+	this.nc += 1
+	nc := this.nc
+	this.lock.Unlock()
+
+	authorization, err := this.authorization(req, &challenge, nc)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	return nil
+}
+
+func (this *digestAuth) Challenge(req *http.Request, res *http.Response) (bool, error) {
+	var challenge *digestChallenge
+	for _, header := range res.Header.Values("WWW-Authenticate") {
+		c := parseDigestChallenge(header)
+		if c == nil {
+			continue
+		}
+		// 服务端提供多个质询时选择最强的算法
+		if challenge == nil || digestAlgorithmRank(c.algorithm) > digestAlgorithmRank(challenge.algorithm) {
+			challenge = c
+		}
+	}
+	if challenge == nil {
+		return false, nil
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.challenge = challenge
+	this.nc = 0
+	return true, nil
+}
+
+// 生成Authorization请求头
+func (this *digestAuth) authorization(req *http.Request, challenge *digestChallenge, nc int64) (string, error) {
+	newHash := digestHash(challenge.algorithm)
+	if newHash == nil {
+		return "", fmt.Errorf("不支持的Digest算法: %s", challenge.algorithm)
+	}
+	h := func(s string) string {
+		hasher := newHash()
+		hasher.Write([]byte(s))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	cnonce := digestCnonce()
+	ha1 := h(this.username + ":" + challenge.realm + ":" + this.password)
+	if strings.HasSuffix(strings.ToLower(challenge.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+	uri := req.URL.RequestURI()
+	ha2 := h(req.Method + ":" + uri)
+	if challenge.qop == "auth-int" {
+		body, err := digestBody(req)
+		if err != nil {
+			return "", err
+		}
+		ha2 = h(req.Method + ":" + uri + ":" + h(string(body)))
+	}
+
+	ncValue := fmt.Sprintf("%08x", nc)
+	var response string
+	if challenge.qop == "" {
+		response = h(ha1 + ":" + challenge.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + challenge.nonce + ":" + ncValue + ":" + cnonce + ":" + challenge.qop + ":" + ha2)
+	}
+
+	username := this.username
+	if challenge.userhash {
+		username = h(this.username + ":" + challenge.realm)
+	}
+	params := []string{
+		fmt.Sprintf(`username=%q`, username),
+		fmt.Sprintf(`realm=%q`, challenge.realm),
+		fmt.Sprintf(`nonce=%q`, challenge.nonce),
+		fmt.Sprintf(`uri=%q`, uri),
+		fmt.Sprintf(`response=%q`, response),
+	}
+	if challenge.algorithm != "" {
+		params = append(params, "algorithm="+challenge.algorithm)
+	}
+	if challenge.opaque != "" {
+		params = append(params, fmt.Sprintf(`opaque=%q`, challenge.opaque))
+	}
+	if challenge.qop != "" {
+		params = append(params, "qop="+challenge.qop, "nc="+ncValue, fmt.Sprintf(`cnonce=%q`, cnonce))
+	}
+	if challenge.userhash {
+		params = append(params, "userhash=true")
+	}
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+// 解析Digest质询, 不是Digest质询时返回nil
+func parseDigestChallenge(header string) *digestChallenge {
+	header = strings.TrimSpace(header)
+	if len(header) < 7 || !strings.EqualFold(header[:7], "digest ") {
+		return nil
+	}
+	params := parseAuthParams(header[7:])
+	challenge := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+		userhash:  strings.EqualFold(params["userhash"], "true"),
+	}
+	if challenge.nonce == "" || digestHash(challenge.algorithm) == nil {
+		return nil
+	}
+	// 优先使用auth, 服务端只支持auth-int时使用auth-int
+	for _, qop := range strings.Split(params["qop"], ",") {
+		qop = strings.TrimSpace(qop)
+		if qop == "auth" {
+			challenge.qop = qop
+			break
+		}
+		if qop == "auth-int" {
+			challenge.qop = qop
+		}
+	}
+	return challenge
+}
+
+// 解析认证参数, key=value或key="value", 多个参数用逗号分隔
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var builder strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				builder.WriteByte(s[i])
+			}
+			value = builder.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// Digest算法对应的哈希函数, 不支持的算法返回nil
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	}
+	return nil
+}
+
+func digestAlgorithmRank(algorithm string) int {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "SHA-512-256":
+		return 3
+	case "SHA-256":
+		return 2
+	}
+	return 1
+}
+
+func digestCnonce() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("生成cnonce失败: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// 读取auth-int需要的请求体, 通过GetBody读取副本, 不影响发送
+func digestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("Digest认证的auth-int需要可以重复读取的请求体")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
@@ -0,0 +1,182 @@
+package gogorequest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// 请求体与响应体编解码器
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// 按Content-Type注册的编解码器
+var (
+	codecs     = map[string]Codec{}
+	codecsLock sync.RWMutex
+)
+
+// 注册编解码器, 相同Content-Type重复注册时覆盖原有编解码器
+func RegisterCodec(contentType string, codec Codec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	codecs[strings.ToLower(contentType)] = codec
+}
+
+// 根据Content-Type查找编解码器, 支持application/*+json、application/*+xml形式的结构化后缀
+func getCodec(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	if codec, ok := codecs[mediaType]; ok {
+		return codec, true
+	}
+	if index := strings.LastIndex(mediaType, "+"); index != -1 {
+		if codec, ok := codecs["application/"+mediaType[index+1:]]; ok {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// 从请求头中读取Content-Type, 请求头名称不区分大小写
+func headerContentType(headers map[string]string) string {
+	for h, hv := range headers {
+		if strings.EqualFold(h, "Content-Type") {
+			return hv
+		}
+	}
+	return ""
+}
+
+// 按响应的Content-Type将响应内容解码到v, 未声明或未注册的类型按JSON解码
+func decodeBody(contentType string, data []byte, v interface{}) error {
+	codec, ok := getCodec(contentType)
+	if !ok {
+		codec = jsonCodec{}
+	}
+	return codec.Unmarshal(data, v)
+}
+
+// JSON编解码器
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// XML编解码器
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// Protobuf编解码器, 值必须实现proto.Message
+type protobufCodec struct{}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf编码要求proto.Message类型, 实际为: %T", v)
+	}
+	return proto.Marshal(message)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf解码要求proto.Message类型, 实际为: %T", v)
+	}
+	return proto.Unmarshal(data, message)
+}
+
+// MessagePack编解码器
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// 表单编解码器, 支持url.Values、map[string]string、map[string][]string和map[string]interface{}
+type formCodec struct{}
+
+func (formCodec) Marshal(v interface{}) ([]byte, error) {
+	values := url.Values{}
+	switch form := v.(type) {
+	case url.Values:
+		values = form
+	case map[string][]string:
+		values = url.Values(form)
+	case map[string]string:
+		for k, fv := range form {
+			values.Set(k, fv)
+		}
+	case map[string]interface{}:
+		for k, fv := range form {
+			values.Set(k, fmt.Sprint(fv))
+		}
+	default:
+		return nil, fmt.Errorf("表单编码不支持的类型: %T", v)
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (formCodec) Unmarshal(data []byte, v interface{}) error {
+	values, parseErr := url.ParseQuery(string(data))
+	if parseErr != nil {
+		return parseErr
+	}
+	switch form := v.(type) {
+	case *url.Values:
+		*form = values
+	case *map[string][]string:
+		*form = values
+	case *map[string]string:
+		if *form == nil {
+			*form = map[string]string{}
+		}
+		for k := range values {
+			(*form)[k] = values.Get(k)
+		}
+	default:
+		return fmt.Errorf("表单解码不支持的类型: %T", v)
+	}
+	return nil
+}
+
+func init() {
+	RegisterCodec("application/json", jsonCodec{})
+	RegisterCodec("text/json", jsonCodec{})
+	RegisterCodec("application/xml", xmlCodec{})
+	RegisterCodec("text/xml", xmlCodec{})
+	RegisterCodec("application/x-protobuf", protobufCodec{})
+	RegisterCodec("application/protobuf", protobufCodec{})
+	RegisterCodec("application/msgpack", msgpackCodec{})
+	RegisterCodec("application/x-msgpack", msgpackCodec{})
+	RegisterCodec("application/x-www-form-urlencoded", formCodec{})
+}
@@ -0,0 +1,366 @@
+package gogorequest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DoH响应体的最大长度
+const maxDoHResponseSize = 64 * 1024
+
+// 域名解析, 依次使用静态解析、缓存、DoH或自定义DNS服务器
+type dnsResolver struct {
+	lock     sync.Mutex
+	hosts    map[string][]net.IP
+	ttl      time.Duration
+	cache    map[string]*dnsCacheEntry
+	resolver *net.Resolver
+	doh      string
+	client   *http.Client
+}
+
+// 缓存的解析结果, done关闭前为正在解析, 并发解析同一域名时只查询一次
+type dnsCacheEntry struct {
+	done   chan struct{}
+	ips    []net.IP
+	err    error
+	expiry time.Time
+}
+
+func (this *mainEngine) resolver() *dnsResolver {
+	if this.dns == nil {
+		this.dns = &dnsResolver{resolver: net.DefaultResolver}
+	}
+	return this.dns
+}
+
+// 设置静态解析, host为域名或域名:端口, 类似curl的--resolve, 带端口时只对该端口生效并优先于不带端口的配置
+// 不传ips时删除该配置
+func (this *mainEngine) SetHostOverride(host string, ips ...string) error {
+	parsed := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		addr := net.ParseIP(ip)
+		if addr == nil {
+			return fmt.Errorf("无效的IP地址: %s", ip)
+		}
+		parsed = append(parsed, addr)
+	}
+	resolver := this.resolver()
+	resolver.lock.Lock()
+	defer resolver.lock.Unlock()
+	if resolver.hosts == nil {
+		resolver.hosts = map[string][]net.IP{}
+	}
+	key := strings.ToLower(host)
+	if len(parsed) == 0 {
+		delete(resolver.hosts, key)
+	} else {
+		resolver.hosts[key] = parsed
+	}
+	return nil
+}
+
+// 开启DNS缓存, 解析结果缓存ttl时间, DoH的结果不超过记录本身的TTL, ttl为0时关闭缓存
+func (this *mainEngine) EnableDNSCache(ttl time.Duration) {
+	resolver := this.resolver()
+	resolver.lock.Lock()
+	defer resolver.lock.Unlock()
+	resolver.ttl = ttl
+	resolver.cache = map[string]*dnsCacheEntry{}
+}
+
+// 使用自定义DNS服务器解析域名, 如8.8.8.8:53, 省略端口时为53
+func (this *mainEngine) SetDNSServer(server string) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	resolver := this.resolver()
+	resolver.lock.Lock()
+	defer resolver.lock.Unlock()
+	resolver.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// 使用DNS-over-HTTPS解析域名, 如https://1.1.1.1/dns-query, 优先于自定义DNS服务器
+// 查询使用独立的client, 不经过引擎的代理和域名解析
+func (this *mainEngine) SetDoHServer(server string) {
+	resolver := this.resolver()
+	resolver.lock.Lock()
+	defer resolver.lock.Unlock()
+	resolver.doh = server
+	resolver.client = &http.Client{Timeout: 5 * time.Second}
+}
+
+// 解析域名, 未配置域名解析时使用系统解析
+func (this *mainEngine) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if this.dns == nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		ips := make([]net.IP, 0, len(addrs))
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+		return ips, nil
+	}
+	return this.dns.lookup(ctx, host, "")
+}
+
+// 解析域名, port不为空时优先使用带端口的静态解析
+func (this *dnsResolver) lookup(ctx context.Context, host string, port string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	this.lock.Lock()
+	if ips, ok := this.hosts[net.JoinHostPort(host, port)]; ok && port != "" {
+		this.lock.Unlock()
+		return ips, nil
+	}
+	if ips, ok := this.hosts[host]; ok {
+		this.lock.Unlock()
+		return ips, nil
+	}
+	if this.ttl <= 0 {
+		this.lock.Unlock()
+		ips, _, err := this.query(ctx, host)
+		return ips, err
+	}
+	entry, ok := this.cache[host]
+	if ok && (entry.expiry.IsZero() || time.Now().Before(entry.expiry)) {
+		this.lock.Unlock()
+		select {
+		case <-entry.done:
+			return entry.ips, entry.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	entry = &dnsCacheEntry{done: make(chan struct{})}
+	this.cache[host] = entry
+	this.lock.Unlock()
+
+	// 解析不受单个请求取消的影响, 其他等待的请求共用结果
+	ips, ttl, err := this.query(context.Background(), host)
+	this.lock.Lock()
+	entry.ips, entry.err = ips, err
+	if err != nil {
+		// 解析失败不缓存
+		delete(this.cache, host)
+		entry.expiry = time.Now()
+	} else {
+		if ttl <= 0 || ttl > this.ttl {
+			ttl = this.ttl
+		}
+		entry.expiry = time.Now().Add(ttl)
+	}
+	this.lock.Unlock()
+	close(entry.done)
+	return ips, err
+}
+
+// 查询域名, 返回IP和记录的TTL, 非DoH查询时TTL为0
+func (this *dnsResolver) query(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	this.lock.Lock()
+	resolver, doh, client := this.resolver, this.doh, this.client
+	this.lock.Unlock()
+	if doh != "" {
+		return queryDoH(ctx, client, doh, host)
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, 0, nil
+}
+
+// 通过DoH查询A和AAAA记录
+func queryDoH(ctx context.Context, client *http.Client, server string, host string) ([]net.IP, time.Duration, error) {
+	type result struct {
+		ips []net.IP
+		ttl time.Duration
+		err error
+	}
+	types := []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	results := make(chan result, len(types))
+	for _, qtype := range types {
+		go func(qtype dnsmessage.Type) {
+			ips, ttl, err := exchangeDoH(ctx, client, server, host, qtype)
+			results <- result{ips, ttl, err}
+		}(qtype)
+	}
+	var ips []net.IP
+	var ttl time.Duration
+	var lastErr error
+	for range types {
+		r := <-results
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		ips = append(ips, r.ips...)
+		if len(r.ips) > 0 && (ttl == 0 || r.ttl < ttl) {
+			ttl = r.ttl
+		}
+	}
+	if len(ips) == 0 {
+		dnsErr := &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}
+		if lastErr != nil {
+			dnsErr.Err = lastErr.Error()
+			dnsErr.IsNotFound = false
+			dnsErr.IsTemporary = true
+		}
+		return nil, 0, dnsErr
+	}
+	// IPv4地址排在前面, 与系统解析的顺序一致
+	v4 := make([]net.IP, 0, len(ips))
+	v6 := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	return append(v4, v6...), ttl, nil
+}
+
+// 发送一次DoH查询, 使用RFC 8484的POST方式
+func exchangeDoH(ctx context.Context, client *http.Client, server string, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+	message := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := message.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", server, bytes.NewReader(packed))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("DoH查询失败: %s", res.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxDoHResponseSize))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(body); err != nil {
+		return nil, 0, fmt.Errorf("DoH响应无效: %w", err)
+	}
+	switch answer.RCode {
+	case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+	default:
+		return nil, 0, fmt.Errorf("DoH查询失败: %s", answer.RCode)
+	}
+	var ips []net.IP
+	var ttl uint32
+	for _, resource := range answer.Answers {
+		var ip net.IP
+		switch body := resource.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(body.AAAA[:])
+		default:
+			// CNAME等记录由服务端递归解析, 只取地址记录
+			continue
+		}
+		ips = append(ips, ip)
+		if len(ips) == 1 || resource.Header.TTL < ttl {
+			ttl = resource.Header.TTL
+		}
+	}
+	return ips, time.Duration(ttl) * time.Second, nil
+}
+
+// 解析域名后依次连接各IP, 返回第一个成功的连接, 设置了出口IP时绑定同协议的本地地址
+func (this *mainEngine) dialResolved(ctx context.Context, dialer *net.Dialer, network string, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	if this.dns != nil {
+		ips, err = this.dns.lookup(ctx, host, port)
+	} else {
+		ips, err = this.lookupIP(ctx, host)
+	}
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	if this.local != nil {
+		network = this.local.network(network)
+	}
+	var lastErr error
+	for _, ip := range filterIPs(ips, network) {
+		if this.local != nil {
+			localAddr, ok := this.local.next(ip)
+			if !ok {
+				continue
+			}
+			dialer.LocalAddr = localAddr
+		}
+		conn, dialErr := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if dialErr == nil {
+			return conn, nil
+		}
+		lastErr = dialErr
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if lastErr == nil {
+		lastErr = &net.OpError{Op: "dial", Net: network, Err: &net.AddrError{Err: "no suitable address found", Addr: host}}
+	}
+	return nil, lastErr
+}
+
+// 按网络类型过滤IP, tcp4只保留IPv4, tcp6只保留IPv6
+func filterIPs(ips []net.IP, network string) []net.IP {
+	if !strings.HasSuffix(network, "4") && !strings.HasSuffix(network, "6") {
+		return ips
+	}
+	filtered := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		if (ip.To4() != nil) == strings.HasSuffix(network, "4") {
+			filtered = append(filtered, ip)
+		}
+	}
+	return filtered
+}
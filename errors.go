@@ -0,0 +1,179 @@
+package gogorequest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// 请求错误类别, 可通过errors.Is判断
+var (
+	ErrTimeout      = errors.New("请求超时")
+	ErrDNS          = errors.New("域名解析失败")
+	ErrConnRefused  = errors.New("连接被拒绝")
+	ErrTLS          = errors.New("TLS握手失败")
+	ErrProxy        = errors.New("代理错误")
+	ErrBodyRead     = errors.New("读取响应体失败")
+	ErrEncode       = errors.New("请求体编码失败")
+	ErrBodyTooLarge = errors.New("响应体超过大小限制")
+)
+
+// 请求错误, 记录错误类别和原始错误
+type RequestError struct {
+	Kind      error  // 错误类别, 为ErrTimeout等之一, 无法归类时为nil
+	Err       error  // 原始错误
+	RequestID string // 请求ID
+}
+
+func (this *RequestError) Error() string {
+	msg := this.Err.Error()
+	if this.Kind != nil {
+		msg = fmt.Sprintf("%v: %v", this.Kind, this.Err)
+	}
+	return withRequestIDMessage(msg, this.RequestID)
+}
+
+func (this *RequestError) Unwrap() error {
+	return this.Err
+}
+
+func (this *RequestError) Is(target error) bool {
+	return this.Kind != nil && target == this.Kind
+}
+
+// 是否可以重试, 只有超时、连接被拒绝、读取响应体失败和临时的域名解析失败可以重试
+// 请求被取消和无法归类的错误(如URL协议错误)不重试
+func (this *RequestError) Retryable() bool {
+	if errors.Is(this.Err, context.Canceled) {
+		return false
+	}
+	switch this.Kind {
+	case ErrTimeout, ErrConnRefused, ErrBodyRead:
+		return true
+	case ErrDNS:
+		var dnsErr *net.DNSError
+		if errors.As(this.Err, &dnsErr) {
+			return dnsErr.IsTimeout || dnsErr.IsTemporary
+		}
+	}
+	return false
+}
+
+// 响应状态码错误, 保留响应内容
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+	Body       []byte // 响应内容, 设置了大小限制时截断为限制的长度, 不会落盘
+	RequestID  string // 请求ID
+}
+
+func (this *HTTPStatusError) Error() string {
+	msg := fmt.Sprintf("响应状态码错误: %d", this.StatusCode)
+	if this.Status != "" {
+		msg = fmt.Sprintf("响应状态码错误: %s", this.Status)
+	}
+	return withRequestIDMessage(msg, this.RequestID)
+}
+
+// 错误信息后附加请求ID
+func withRequestIDMessage(msg string, id string) string {
+	if id == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s (请求ID: %s)", msg, id)
+}
+
+// 408、425、429和除501外的5xx状态码可以重试
+func (this *HTTPStatusError) Retryable() bool {
+	switch this.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented:
+		return false
+	}
+	return this.StatusCode >= 500
+}
+
+// 响应体超过大小限制的错误详情
+type BodyTooLargeError struct {
+	Limit int64 // 允许的最大字节数
+}
+
+func (this *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("响应体超过大小限制: %d字节", this.Limit)
+}
+
+func (this *BodyTooLargeError) Is(target error) bool {
+	return target == ErrBodyTooLarge
+}
+
+// 判断错误是否可以重试
+func Retryable(err error) bool {
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+	return false
+}
+
+// 包装为指定类别的请求错误
+func wrapError(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return err
+	}
+	return &RequestError{Kind: kind, Err: err}
+}
+
+// 将发送请求时的错误归类为请求错误
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return err
+	}
+	return &RequestError{Kind: errorKind(err), Err: err}
+}
+
+func errorKind(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || opErr.Op == "socks connect") {
+		return ErrProxy
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrDNS
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrConnRefused
+	}
+	var recordErr tls.RecordHeaderError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateErr x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &certificateErr) {
+		return ErrTLS
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && strings.HasPrefix(urlErr.Err.Error(), "tls: ") {
+		return ErrTLS
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrTimeout
+	}
+	return nil
+}
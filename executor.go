@@ -0,0 +1,221 @@
+package gogorequest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// 发送请求并返回未读取响应体的响应, 所有引擎共用, trace不为nil时通过httptrace记录各阶段耗时
+func (this *mainEngine) send(request *Request, trace *requestTrace) (res *http.Response, err error) {
+	client := http.Client{}
+
+	// 设置代理和Transport
+	proxyUrl, addProxyAndTransportErr := this.addProxyAndTransport(&client, request)
+	if addProxyAndTransportErr != nil {
+		return nil, addProxyAndTransportErr
+	}
+
+	// 如果body不等于nil, 则生成请求体
+	payload, encodeErr := encodeBody(request.Body, request.Headers)
+	if encodeErr != nil {
+		return nil, encodeErr
+	}
+
+	// 记录发送的请求体字节数, 长度未知时边发送边统计
+	if payload != nil && trace != nil {
+		if payload.length >= 0 {
+			trace.bytesOut = payload.length
+		} else {
+			payload.reader = newCountingReader(payload.reader, &trace.bytesOut)
+		}
+	}
+
+	// 包装请求体, Unix socket请求改写为HTTP请求
+	targetUrl := request.URL
+	_, unixUrl, isUnix, unixErr := unixRequestURL(targetUrl)
+	if unixErr != nil {
+		return nil, unixErr
+	}
+	if isUnix {
+		targetUrl = unixUrl
+	}
+	req, newRequestErr := newRequest(request.Method, targetUrl, payload)
+	if newRequestErr != nil {
+		return nil, newRequestErr
+	}
+
+	// 设置请求头
+	for h, hv := range request.Headers {
+		req.Header.Add(h, hv)
+	}
+	if header := this.requestIDHeaderName(); header != "" && request.ID != "" && req.Header.Get(header) == "" {
+		req.Header.Set(header, request.ID)
+	}
+	// 添加认证信息
+	auth := this.authenticator(request)
+	if auth != nil {
+		if authErr := auth.Authenticate(req); authErr != nil {
+			return nil, authErr
+		}
+	}
+	// 使用请求的ctx, 开始链路追踪span并注入traceparent请求头
+	ctx, span := this.startSpan(request.context(), request, req)
+	if trace != nil {
+		trace.span = span
+		ctx = trace.withContext(ctx)
+	} else if span != nil {
+		// 没有耗时记录时span只记录到收到响应头为止
+		defer func() {
+			response := Response{Error: err}
+			if res != nil {
+				response.StatusCode = res.StatusCode
+			}
+			endSpan(span, &response)
+		}()
+	}
+	// 设置了响应体空闲超时时, 超时后通过取消请求中断读取
+	var cancel context.CancelFunc
+	if this.timeouts.BodyIdle > 0 {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	req = req.WithContext(withProxy(ctx, proxyUrl))
+
+	// 执行请求
+	res, doErr := client.Do(req)
+	if doErr == nil && res.StatusCode == http.StatusUnauthorized && auth != nil {
+		res, doErr = this.reauthenticate(&client, auth, req, res)
+	}
+	if doErr != nil {
+		if cancel != nil {
+			cancel()
+		}
+		// 错误信息中使用原始的Unix socket地址
+		var urlErr *url.Error
+		if isUnix && errors.As(doErr, &urlErr) {
+			urlErr.URL = request.URL
+		}
+		return res, classifyError(doErr)
+	}
+	if cancel != nil {
+		res.Body = newIdleTimeoutBody(res.Body, this.timeouts.BodyIdle, cancel)
+	}
+	return res, nil
+}
+
+// 执行请求并生成响应, 流式响应模式下onStreamFinish在响应体读取完毕或关闭时调用
+func (this *mainEngine) execute(request *Request, onStreamFinish func()) *Response {
+	this.notifyStarted(request)
+	trace := newRequestTrace(request.queueStart())
+	res, err := this.send(request, trace)
+	endTime := time.Now()
+	if err != nil {
+		response := this.onError(res, err, request, endTime, trace)
+		this.finish(request, response, trace, 0)
+		return response
+	}
+	// 流式响应模式下不读取响应体, 响应体读取完毕或关闭时才算请求结束
+	if this.stream {
+		return this.onStream(res, request, endTime, trace, onStreamFinish)
+	}
+	defer res.Body.Close()
+	// 处理返回数据
+	response := this.onResponse(res, request, endTime, trace)
+	this.finish(request, response, trace, responseBytes(response))
+	return response
+}
+
+// 请求结束, 结束span并通知观察者
+func (this *mainEngine) finish(request *Request, response *Response, trace *requestTrace, bytesIn int64) {
+	endSpan(trace.span, response)
+	this.notifyFinished(request, response, bytesIn, atomic.LoadInt64(&trace.bytesOut))
+}
+
+// 响应体字节数, 落盘时为临时文件大小, 状态码校验失败时为错误中保留的响应内容长度
+func responseBytes(response *Response) int64 {
+	if response.BodyFile != "" {
+		if stat, statErr := os.Stat(response.BodyFile); statErr == nil {
+			return stat.Size()
+		}
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(response.Error, &statusErr) {
+		return int64(len(statusErr.Body))
+	}
+	return int64(len(response.Bytes))
+}
+
+// 生成响应, 记录请求的开始时间、结束时间和耗时
+func (this *mainEngine) newResponse(res *http.Response, request *Request, endTime time.Time, trace *requestTrace) *Response {
+	var response Response
+	// 没有收到响应时状态码为0
+	if res != nil {
+		response.StatusCode = res.StatusCode
+	}
+	response.Request = request
+	response.RequestID = request.ID
+	response.Response = res
+	response.StartTime = request.startTime
+	response.EndTime = endTime
+	response.ConsumeTime = endTime.Sub(request.startTime).Seconds()
+	response.Timings = trace.timings(time.Now())
+	return &response
+}
+
+func (this *mainEngine) onError(res *http.Response, err error, request *Request, endTime time.Time, trace *requestTrace) *Response {
+	response := this.newResponse(res, request, endTime, trace)
+	response.Status = false
+	response.Error = withRequestID(err, request.ID)
+	return response
+}
+
+func (this *mainEngine) onResponse(res *http.Response, request *Request, endTime time.Time, trace *requestTrace) *Response {
+	// 校验响应状态码, 校验失败时在错误中保留响应内容, 设置了大小限制时截断, 不落盘
+	if statusErr := this.checkStatus(res, request.options); statusErr != nil {
+		statusErr.Body = this.readErrorBody(res)
+		return this.onError(res, statusErr, request, endTime, trace)
+	}
+	// 读取响应内容
+	text, bodyFile, err := this.readBody(res)
+	if err != nil {
+		return this.onError(res, err, request, endTime, trace)
+	}
+	response := this.newResponse(res, request, endTime, trace)
+	response.Status = true
+	response.Error = nil
+	if !this.textOff {
+		response.Text = string(text)
+	}
+	response.Bytes = text
+	response.BodyFile = bodyFile
+	return response
+}
+
+func (this *mainEngine) onStream(res *http.Response, request *Request, endTime time.Time, trace *requestTrace, onStreamFinish func()) *Response {
+	// 校验响应状态码, 校验失败时读取响应内容并关闭响应体
+	if statusErr := this.checkStatus(res, request.options); statusErr != nil {
+		defer res.Body.Close()
+		statusErr.Body = this.readErrorBody(res)
+		response := this.onError(res, statusErr, request, endTime, trace)
+		this.finish(request, response, trace, int64(len(statusErr.Body)))
+		return response
+	}
+	response := this.newResponse(res, request, endTime, trace)
+	response.Status = true
+	response.Error = nil
+	// 响应体读取完毕或关闭时更新结束时间和耗时
+	response.Body = &streamBody{body: res.Body, limit: this.maxBodySize, onFinish: func(read int64) {
+		response.EndTime = time.Now()
+		response.ConsumeTime = response.EndTime.Sub(request.startTime).Seconds()
+		response.Timings = trace.timings(response.EndTime)
+		this.finish(request, response, trace, read)
+		if onStreamFinish != nil {
+			onStreamFinish()
+		}
+	}}
+	return response
+}
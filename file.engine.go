@@ -1,18 +1,39 @@
 package gogorequest
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	humanizee "github.com/dustin/go-humanize"
 	"io"
-	"net/http"
 	"os"
 	"strings"
 	"time"
 )
 
+// 进度回调接口, 文件下载引擎与上传引擎共用
+type ProgressReporter interface {
+	// current为已传输字节数, total为总字节数(未知时为0)
+	OnProgress(current uint64, total uint64)
+}
+
+// 默认的终端进度输出
+type consoleProgress struct {
+	action string
+}
+
+func (this consoleProgress) OnProgress(current uint64, total uint64) {
+	fmt.Printf("\r%s", strings.Repeat(" ", 35))
+	if total > 0 {
+		fmt.Printf("\r%s... %s / %s complete", this.action, humanizee.Bytes(current), humanizee.Bytes(total))
+	} else {
+		fmt.Printf("\r%s... %s complete", this.action, humanizee.Bytes(current))
+	}
+}
+
 type writeCounter struct {
-	Total uint64
+	Total    uint64
+	Size     uint64
+	reporter ProgressReporter
 }
 
 func (wc *writeCounter) Write(p []byte) (int, error) {
@@ -22,132 +43,90 @@ func (wc *writeCounter) Write(p []byte) (int, error) {
 	return n, nil
 }
 func (wc writeCounter) PrintProgress() {
-	fmt.Printf("\r%s", strings.Repeat(" ", 35))
-	fmt.Printf("\rDownloading... %s complete", humanizee.Bytes(wc.Total))
+	wc.reporter.OnProgress(wc.Total, wc.Size)
 }
 
 type FileEngine struct {
 	mainEngine // 继承主引擎
 }
 
-func (this *FileEngine) Visit(method string, targetUrl string, headers map[string]string, body interface{}, timeout time.Duration, proxies string, filepath string) *FileEngineResponse {
-	request := fileEngineRequestBody{
+func (this *FileEngine) Visit(method string, targetUrl string, headers map[string]string, body interface{}, timeout time.Duration, proxies string, filepath string, opts ...RequestOption) *FileEngineResponse {
+	options := newRequestOptions(opts)
+	request := Request{
+		ID:        this.requestID(headers, options),
 		URL:       targetUrl,
 		Method:    method,
 		Headers:   headers,
 		Body:      body,
-		Spider:    this,
 		Proxy:     proxies,
 		Timeout:   timeout,
 		FilePath:  filepath,
+		engine:    this,
 		startTime: time.Now(),
+		options:   options,
 	}
 	return this.get(&request)
 }
 
-func (this *FileEngine) get(request *fileEngineRequestBody) *FileEngineResponse {
-	client := http.Client{}
-	defer client.CloseIdleConnections()
-
-	// 设置代理和Transport
-	addProxyAndTransportErr := this.addProxyAndTransport(&client, request.Proxy, request.Timeout)
-	if addProxyAndTransportErr != nil {
-		return this.onError(nil, addProxyAndTransportErr, request, request.startTime, time.Now(), time.Now().Sub(request.startTime).Seconds())
-	}
+// 重新下载文件
+func (this *FileEngine) retry(request *Request) *Response {
+	return this.get(request.next())
+}
 
-	// 如果body不等于nil, 则生成reader类型body
-	var payload *strings.Reader
-	if request.Body != nil {
-		requestBody, isString := request.Body.(string)
-		if !isString {
-			bodyJson, marshalErr := json.Marshal(requestBody)
-			if marshalErr != nil {
-				return this.onError(nil, marshalErr, request, request.startTime, time.Now(), time.Now().Sub(request.startTime).Seconds())
-			}
-			payload = strings.NewReader(string(bodyJson))
-		} else {
-			payload = strings.NewReader(requestBody)
-		}
-	} else {
-		payload = nil
-	}
+func (this *FileEngine) get(request *Request) *FileEngineResponse {
+	this.notifyStarted(request)
+	response, written, trace := this.download(request)
+	this.finish(request, response, trace, written)
+	return response
+}
 
-	// 包装请求体
-	var req *http.Request
-	var newRequestErr error
-	if payload == nil {
-		req, newRequestErr = http.NewRequest(request.Method, request.URL, nil)
-		if newRequestErr != nil {
-			return this.onError(nil, newRequestErr, request, request.startTime, time.Now(), time.Now().Sub(request.startTime).Seconds())
-		}
-	} else {
-		req, newRequestErr = http.NewRequest(request.Method, request.URL, payload)
-		if newRequestErr != nil {
-			return this.onError(nil, newRequestErr, request, request.startTime, time.Now(), time.Now().Sub(request.startTime).Seconds())
-		}
+// 下载文件, 返回响应、写入文件的字节数和请求耗时记录
+func (this *FileEngine) download(request *Request) (*FileEngineResponse, int64, *requestTrace) {
+	trace := newRequestTrace(request.queueStart())
+	res, sendErr := this.send(request, trace)
+	endTime := time.Now()
+	if sendErr != nil {
+		return this.onError(res, sendErr, request, endTime, trace), 0, trace
 	}
+	defer res.Body.Close()
 
-	// 设置请求头
-	for h, hv := range request.Headers {
-		req.Header.Add(h, hv)
+	// 校验响应状态码, 校验失败时不写入文件
+	if statusErr := this.checkStatus(res, request.options); statusErr != nil {
+		statusErr.Body = this.readErrorBody(res)
+		return this.onError(res, statusErr, request, endTime, trace), int64(len(statusErr.Body)), trace
 	}
 
-	// 执行请求
-	res, doErr := client.Do(req)
-	endTime := time.Now()
-	consumeTime := endTime.Sub(request.startTime).Seconds()
-	if doErr != nil {
-		return this.onError(res, doErr, request, request.startTime, endTime, consumeTime)
+	counter := &writeCounter{reporter: this.progressReporter("Downloading")}
+	if res.ContentLength > 0 {
+		counter.Size = uint64(res.ContentLength)
 	}
-	defer res.Body.Close()
-
-	counter := &writeCounter{}
 	file, openFileErr := os.OpenFile(request.FilePath, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0666)
 	if openFileErr != nil {
-		return this.onError(nil, openFileErr, request, request.startTime, time.Now(), time.Now().Sub(request.startTime).Seconds())
+		return this.onError(res, openFileErr, request, time.Now(), trace), 0, trace
 	}
 	defer file.Close()
-	_, copyErr := io.Copy(file, io.TeeReader(res.Body, counter))
+	written, copyErr := io.Copy(file, io.TeeReader(res.Body, counter))
 	if copyErr != nil {
-		return this.onError(nil, copyErr, request, request.startTime, time.Now(), time.Now().Sub(request.startTime).Seconds())
+		// 写文件失败时保留原始错误, 否则为读取响应体失败
+		var pathErr *os.PathError
+		if !errors.As(copyErr, &pathErr) {
+			copyErr = bodyReadError(copyErr)
+		}
+		return this.onError(res, copyErr, request, time.Now(), trace), written, trace
 	}
 
 	// 处理返回数据
-	return this.onResponse(res, request, request.startTime, endTime, consumeTime)
-}
-
-func (this *FileEngine) onError(res *http.Response, err error, request *fileEngineRequestBody, startTime time.Time, endTime time.Time, consumeTime float64) *FileEngineResponse {
-	var response FileEngineResponse
-	if res != nil {
-		response.StatusCode = res.StatusCode
-	} else {
-		response.StatusCode = 10000
-	}
-	response.Status = false
-	response.Error = err
-	response.Request = request
-	response.StartTime = startTime
-	response.EndTime = endTime
-	response.ConsumeTime = consumeTime
-	return &response
-}
-
-func (this *FileEngine) onResponse(res *http.Response, request *fileEngineRequestBody, startTime time.Time, endTime time.Time, consumeTime float64) *FileEngineResponse {
-	var response FileEngineResponse
+	response := this.newResponse(res, request, endTime, trace)
 	response.Status = true
 	response.Error = nil
-	response.Request = request
-	response.StatusCode = res.StatusCode
 	response.Text = "OK"
-	response.StartTime = startTime
-	response.EndTime = endTime
-	response.ConsumeTime = consumeTime
-	return &response
+	return response, written, trace
 }
 
 // 实例化文件下载引擎
 func NewFileEngine() *FileEngine {
 	s := FileEngine{}
 	s.initTransport()
+	s.initStats()
 	return &s
 }
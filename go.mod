@@ -4,7 +4,14 @@ go 1.17
 
 require (
 	github.com/dustin/go-humanize v1.0.0
+	github.com/robertkrimen/otto v0.2.1
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	golang.org/x/net v0.0.0-20220421235706-1d1ef9303861
+	google.golang.org/protobuf v1.28.1
 )
 
-require golang.org/x/text v0.3.7 // indirect
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/text v0.4.0 // indirect
+	gopkg.in/sourcemap.v1 v1.0.5 // indirect
+)
@@ -0,0 +1,251 @@
+package gogorequest
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 签名使用的哈希算法
+type SignHash int
+
+const (
+	SignSHA256 SignHash = iota
+	SignSHA1
+	SignMD5
+)
+
+func (this SignHash) new() func() hash.Hash {
+	switch this {
+	case SignSHA1:
+		return sha1.New
+	case SignMD5:
+		return md5.New
+	}
+	return sha256.New
+}
+
+// 签名结果的编码方式
+type SignEncoding int
+
+const (
+	SignBase64 SignEncoding = iota
+	SignHex
+)
+
+func (this SignEncoding) encode(data []byte) string {
+	if this == SignHex {
+		return hex.EncodeToString(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// 参与签名的字段
+type SignField int
+
+const (
+	SignFieldMethod    SignField = iota // 请求方法, 大写
+	SignFieldPath                       // URL路径, 为空时为/
+	SignFieldQuery                      // 按参数名排序并编码的URL参数, 输出到URL参数时包含时间戳和随机数
+	SignFieldHeaders                    // SignHeaders中的请求头, 按名称排序, 每行为小写名称:值
+	SignFieldBodyHash                   // 请求体哈希, 使用BodyHash算法, 十六进制编码
+	SignFieldTimestamp                  // 时间戳
+	SignFieldNonce                      // 随机数
+	SignFieldSecret                     // 密钥, 用于钉钉等将密钥拼入签名字符串的平台
+)
+
+// 默认参与签名的字段
+var defaultSignFields = []SignField{SignFieldMethod, SignFieldPath, SignFieldQuery, SignFieldHeaders, SignFieldBodyHash, SignFieldTimestamp, SignFieldNonce}
+
+// HMAC签名配置, 零值字段使用默认值
+type HMACSignerConfig struct {
+	Secret        string
+	Hash          SignHash      // HMAC算法, 默认HMAC-SHA256
+	Encoding      SignEncoding  // 签名编码, 默认base64
+	BodyHash      SignHash      // 请求体哈希算法, 默认SHA256
+	Fields        []SignField   // 签名字段及顺序, 默认为全部字段
+	Separator     string        // 字段分隔符, 默认为换行
+	SignHeaders   []string      // SignFieldHeaders包含的请求头
+	TimestampUnit time.Duration // 时间戳单位, time.Second或time.Millisecond, 默认秒
+	TimestampName string        // 时间戳的请求头或URL参数名, 默认X-Timestamp, 签名字段包含时间戳时才发送
+	NonceName     string        // 随机数的请求头或URL参数名, 默认X-Nonce, 签名字段包含随机数时才发送
+	SignatureName string        // 签名的请求头或URL参数名, 默认X-Signature
+	InQuery       bool          // 为true时时间戳、随机数和签名放在URL参数中, 否则放在请求头中
+}
+
+// 通用HMAC请求签名, 可作为引擎或请求的认证方式
+// 由请求方法、路径、排序后的参数、请求头、请求体哈希、时间戳和随机数拼接签名字符串
+type HMACSigner struct {
+	config HMACSignerConfig
+}
+
+// 实例化HMAC请求签名
+func NewHMACSigner(config HMACSignerConfig) *HMACSigner {
+	if config.Fields == nil {
+		config.Fields = defaultSignFields
+	}
+	if config.Separator == "" {
+		config.Separator = "\n"
+	}
+	if config.TimestampUnit == 0 {
+		config.TimestampUnit = time.Second
+	}
+	if config.TimestampName == "" {
+		config.TimestampName = "X-Timestamp"
+	}
+	if config.NonceName == "" {
+		config.NonceName = "X-Nonce"
+	}
+	if config.SignatureName == "" {
+		config.SignatureName = "X-Signature"
+	}
+	return &HMACSigner{config: config}
+}
+
+// 签名请求
+func (this *HMACSigner) Authenticate(req *http.Request) error {
+	timestamp := strconv.FormatInt(time.Now().UnixNano()/int64(this.config.TimestampUnit), 10)
+	nonce := signNonce()
+	params := map[string]string{}
+	if this.hasField(SignFieldTimestamp) {
+		params[this.config.TimestampName] = timestamp
+	}
+	if this.hasField(SignFieldNonce) {
+		params[this.config.NonceName] = nonce
+	}
+	if this.config.InQuery {
+		query := req.URL.Query()
+		for k, v := range params {
+			query.Set(k, v)
+		}
+		query.Del(this.config.SignatureName)
+		req.URL.RawQuery = query.Encode()
+	} else {
+		for k, v := range params {
+			req.Header.Set(k, v)
+		}
+	}
+
+	stringToSign, err := this.stringToSign(req, timestamp, nonce)
+	if err != nil {
+		return err
+	}
+	signature := hmacSign(this.config.Hash, this.config.Encoding, []byte(this.config.Secret), []byte(stringToSign))
+	if this.config.InQuery {
+		query := req.URL.Query()
+		query.Set(this.config.SignatureName, signature)
+		req.URL.RawQuery = query.Encode()
+	} else {
+		req.Header.Set(this.config.SignatureName, signature)
+	}
+	return nil
+}
+
+func (this *HMACSigner) hasField(field SignField) bool {
+	for _, f := range this.config.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// 按配置的字段拼接签名字符串
+func (this *HMACSigner) stringToSign(req *http.Request, timestamp string, nonce string) (string, error) {
+	parts := make([]string, 0, len(this.config.Fields))
+	for _, field := range this.config.Fields {
+		switch field {
+		case SignFieldMethod:
+			parts = append(parts, strings.ToUpper(req.Method))
+		case SignFieldPath:
+			path := req.URL.EscapedPath()
+			if path == "" {
+				path = "/"
+			}
+			parts = append(parts, path)
+		case SignFieldQuery:
+			parts = append(parts, req.URL.Query().Encode())
+		case SignFieldHeaders:
+			parts = append(parts, this.canonicalHeaders(req))
+		case SignFieldBodyHash:
+			bodyHash, err := this.bodyHash(req)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, bodyHash)
+		case SignFieldTimestamp:
+			parts = append(parts, timestamp)
+		case SignFieldNonce:
+			parts = append(parts, nonce)
+		case SignFieldSecret:
+			parts = append(parts, this.config.Secret)
+		}
+	}
+	return strings.Join(parts, this.config.Separator), nil
+}
+
+func (this *HMACSigner) canonicalHeaders(req *http.Request) string {
+	names := make([]string, 0, len(this.config.SignHeaders))
+	for _, name := range this.config.SignHeaders {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" && value == "" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		lines = append(lines, name+":"+strings.TrimSpace(value))
+	}
+	return strings.Join(lines, this.config.Separator)
+}
+
+// 请求体哈希, 请求体需可以重复读取
+func (this *HMACSigner) bodyHash(req *http.Request) (string, error) {
+	hasher := this.config.BodyHash.new()()
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return "", errors.New("签名请求体需要可以重复读取的请求体")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		if _, err := io.Copy(hasher, body); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// 计算HMAC签名, 飞书预警消息的签名也使用此方法
+func hmacSign(hash SignHash, encoding SignEncoding, key []byte, message []byte) string {
+	mac := hmac.New(hash.new(), key)
+	mac.Write(message)
+	return encoding.encode(mac.Sum(nil))
+}
+
+func signNonce() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("生成随机数失败: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,228 @@
+package gogorequest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWT签名算法
+type JWTAlgorithm string
+
+const (
+	JWTHS256 JWTAlgorithm = "HS256"
+	JWTRS256 JWTAlgorithm = "RS256"
+	JWTES256 JWTAlgorithm = "ES256"
+)
+
+const (
+	defaultJWTTTL         = 5 * time.Minute
+	defaultJWTExpiryDelta = 30 * time.Second
+)
+
+// JWT签名配置
+type JWTConfig struct {
+	Algorithm   JWTAlgorithm
+	Key         []byte                 // HS256的密钥, 或RS256、ES256的PEM私钥
+	KeyFile     string                 // 从文件读取Key, Key为空时使用
+	KeyID       string                 // 头部的kid
+	Issuer      string                 // iss
+	Subject     string                 // sub
+	Audience    []string               // aud, 只有一个时输出为字符串
+	Claims      map[string]interface{} // 自定义声明, 与标准声明同名时以标准声明为准
+	TTL         time.Duration          // 有效期, 默认5分钟
+	ExpiryDelta time.Duration          // 提前重新签发的时间, 默认30秒, 不超过有效期的一半
+}
+
+// JWT签名, 签发短期有效的JWT并作为Bearer令牌发送, 过期前重新签发
+type JWTSigner struct {
+	config JWTConfig
+	key    interface{}
+	lock   sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// 实例化JWT签名, 密钥无效时返回错误
+func NewJWTSigner(config JWTConfig) (*JWTSigner, error) {
+	keyData := config.Key
+	if len(keyData) == 0 && config.KeyFile != "" {
+		data, readErr := ioutil.ReadFile(config.KeyFile)
+		if readErr != nil {
+			return nil, readErr
+		}
+		keyData = data
+	}
+	if len(keyData) == 0 {
+		return nil, errors.New("JWT签名缺少密钥")
+	}
+	key, keyErr := parseJWTKey(config.Algorithm, keyData)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultJWTTTL
+	}
+	if config.ExpiryDelta <= 0 {
+		config.ExpiryDelta = defaultJWTExpiryDelta
+	}
+	if config.ExpiryDelta > config.TTL/2 {
+		config.ExpiryDelta = config.TTL / 2
+	}
+	return &JWTSigner{config: config, key: key}, nil
+}
+
+// 解析密钥, HS256直接使用密钥, RS256和ES256解析PEM私钥
+func parseJWTKey(algorithm JWTAlgorithm, data []byte) (interface{}, error) {
+	switch algorithm {
+	case JWTHS256:
+		return data, nil
+	case JWTRS256, JWTES256:
+	default:
+		return nil, fmt.Errorf("不支持的JWT算法: %s", algorithm)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("JWT私钥不是有效的PEM格式")
+	}
+	var key interface{}
+	var parseErr error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, parseErr = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, parseErr = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, parseErr = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("解析JWT私钥失败: %w", parseErr)
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if algorithm == JWTRS256 {
+			return k, nil
+		}
+	case *ecdsa.PrivateKey:
+		if algorithm == JWTES256 && k.Curve == elliptic.P256() {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("JWT私钥类型与算法%s不匹配", algorithm)
+}
+
+// 获取JWT, 缓存的JWT即将过期时重新签发
+func (this *JWTSigner) Token() (string, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	now := time.Now()
+	if this.token != "" && now.Add(this.config.ExpiryDelta).Before(this.expiry) {
+		return this.token, nil
+	}
+	token, expiry, err := this.mint(now)
+	if err != nil {
+		return "", err
+	}
+	this.token = token
+	this.expiry = expiry
+	return token, nil
+}
+
+// 签发JWT
+func (this *JWTSigner) mint(now time.Time) (string, time.Time, error) {
+	header := map[string]interface{}{"alg": string(this.config.Algorithm), "typ": "JWT"}
+	if this.config.KeyID != "" {
+		header["kid"] = this.config.KeyID
+	}
+
+	expiry := now.Add(this.config.TTL)
+	claims := map[string]interface{}{}
+	for k, v := range this.config.Claims {
+		claims[k] = v
+	}
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = expiry.Unix()
+	claims["jti"] = signNonce()
+	if this.config.Issuer != "" {
+		claims["iss"] = this.config.Issuer
+	}
+	if this.config.Subject != "" {
+		claims["sub"] = this.config.Subject
+	}
+	if len(this.config.Audience) == 1 {
+		claims["aud"] = this.config.Audience[0]
+	} else if len(this.config.Audience) > 1 {
+		claims["aud"] = this.config.Audience
+	}
+
+	headerJSON, headerErr := json.Marshal(header)
+	if headerErr != nil {
+		return "", expiry, headerErr
+	}
+	claimsJSON, claimsErr := json.Marshal(claims)
+	if claimsErr != nil {
+		return "", expiry, fmt.Errorf("JWT声明编码失败: %w", claimsErr)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	signature, signErr := this.sign([]byte(signingInput))
+	if signErr != nil {
+		return "", expiry, signErr
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), expiry, nil
+}
+
+func (this *JWTSigner) sign(data []byte) ([]byte, error) {
+	switch key := this.key.(type) {
+	case []byte:
+		return hmacSHA256(key, string(data)), nil
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(data)
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// ES256的签名为定长的R和S拼接
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+	return nil, errors.New("无效的JWT私钥")
+}
+
+// 添加Authorization: Bearer请求头
+func (this *JWTSigner) Authenticate(req *http.Request) error {
+	token, err := this.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// 收到401响应时作废请求使用的JWT, 重新签发后再发送一次请求, 用于服务端因时钟偏差提前判定过期的情况
+func (this *JWTSigner) Challenge(req *http.Request, res *http.Response) (bool, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.token != "" && req.Header.Get("Authorization") == "Bearer "+this.token {
+		this.token = ""
+	}
+	return true, nil
+}
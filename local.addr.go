@@ -0,0 +1,89 @@
+package gogorequest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// 建立连接使用的IP协议
+type IPFamily int
+
+const (
+	IPDualStack IPFamily = iota // 同时使用IPv4和IPv6
+	IPv4Only                    // 只使用IPv4
+	IPv6Only                    // 只使用IPv6
+)
+
+// 出口IP配置, 每个协议的本地地址分别轮换
+// 原子操作的计数器放在结构体开头, 保证在32位平台上按8字节对齐
+type localAddrs struct {
+	v4Next uint64
+	v6Next uint64
+	family IPFamily
+	v4     []net.IP
+	v6     []net.IP
+}
+
+func (this *mainEngine) localAddrConfig() *localAddrs {
+	if this.local == nil {
+		this.local = &localAddrs{}
+	}
+	return this.local
+}
+
+// 设置出口IP, 一个时绑定该IP, 多个时按连接轮换, 连接目标的IPv4或IPv6地址时使用同协议的本地地址
+// 不传ips时取消绑定
+func (this *mainEngine) SetLocalAddrs(ips ...string) error {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		addr := net.ParseIP(ip)
+		if addr == nil {
+			return fmt.Errorf("无效的出口IP: %s", ip)
+		}
+		if v4Addr := addr.To4(); v4Addr != nil {
+			v4 = append(v4, v4Addr)
+		} else {
+			v6 = append(v6, addr)
+		}
+	}
+	local := this.localAddrConfig()
+	local.v4, local.v6 = v4, v6
+	return nil
+}
+
+// 设置建立连接使用的IP协议, 默认同时使用IPv4和IPv6
+func (this *mainEngine) SetIPFamily(family IPFamily) {
+	this.localAddrConfig().family = family
+}
+
+// 按IP协议配置调整网络类型, tcp在只使用IPv4时为tcp4, 只使用IPv6时为tcp6
+func (this *localAddrs) network(network string) string {
+	if strings.HasSuffix(network, "4") || strings.HasSuffix(network, "6") {
+		return network
+	}
+	switch this.family {
+	case IPv4Only:
+		return network + "4"
+	case IPv6Only:
+		return network + "6"
+	}
+	return network
+}
+
+// 为连接目标选择同协议的本地地址, 未设置出口IP时返回nil, 设置了但没有同协议的地址时ok为false
+func (this *localAddrs) next(target net.IP) (addr net.Addr, ok bool) {
+	pool, counter := this.v6, &this.v6Next
+	if target.To4() != nil {
+		pool, counter = this.v4, &this.v4Next
+	}
+	if len(this.v4) == 0 && len(this.v6) == 0 {
+		return nil, true
+	}
+	if len(pool) == 0 {
+		return nil, false
+	}
+	n := atomic.AddUint64(counter, 1) - 1
+	return &net.TCPAddr{IP: pool[n%uint64(len(pool))]}, true
+}
@@ -0,0 +1,319 @@
+package gogorequest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 日志级别
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota // 请求开始、请求头、请求体和响应体
+	LevelInfo                  // 请求完成、重试
+	LevelWarn                  // 状态码校验失败
+	LevelError                 // 请求失败
+)
+
+// 默认的请求体、响应体日志截断长度
+const defaultLogBodyLimit = 512
+
+// 脱敏后的请求头和参数值
+const redactedValue = "[REDACTED]"
+
+// 结构化日志接口, 方法签名与log/slog一致, *slog.Logger可以直接使用
+// args为交替的键和值
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// 基于标准库log.Logger的日志, 输出为 "级别 消息 key=value ..." 格式
+type stdLogger struct {
+	logger *log.Logger
+}
+
+// 将*log.Logger包装为Logger, logger为nil时使用log.Default()
+func NewStdLogger(logger *log.Logger) Logger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &stdLogger{logger: logger}
+}
+
+func (this *stdLogger) Debug(msg string, args ...interface{}) {
+	this.output("DEBUG", msg, args)
+}
+
+func (this *stdLogger) Info(msg string, args ...interface{}) {
+	this.output("INFO", msg, args)
+}
+
+func (this *stdLogger) Warn(msg string, args ...interface{}) {
+	this.output("WARN", msg, args)
+}
+
+func (this *stdLogger) Error(msg string, args ...interface{}) {
+	this.output("ERROR", msg, args)
+}
+
+func (this *stdLogger) output(level string, msg string, args []interface{}) {
+	var builder strings.Builder
+	builder.WriteString(level)
+	builder.WriteString(" ")
+	builder.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		builder.WriteString(" ")
+		if i+1 >= len(args) {
+			builder.WriteString(logValue(args[i]))
+			break
+		}
+		builder.WriteString(fmt.Sprint(args[i]))
+		builder.WriteString("=")
+		builder.WriteString(logValue(args[i+1]))
+	}
+	this.logger.Output(3, builder.String())
+}
+
+// 格式化日志值, 含空格、引号或为空时加引号
+func logValue(value interface{}) string {
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// 为引擎设置日志, level为最低输出级别
+func (this *mainEngine) SetLogger(logger Logger, level LogLevel) {
+	requestLogger := this.requestLogger()
+	requestLogger.lock.Lock()
+	defer requestLogger.lock.Unlock()
+	requestLogger.logger = logger
+	requestLogger.level = level
+}
+
+// 设置日志中请求体、响应体的截断长度, 小于等于0时不输出请求体和响应体
+func (this *mainEngine) SetLogBodyLimit(limit int) {
+	requestLogger := this.requestLogger()
+	requestLogger.lock.Lock()
+	defer requestLogger.lock.Unlock()
+	requestLogger.bodyLimit = limit
+}
+
+// 添加需要脱敏的请求头或URL参数名, 不区分大小写
+func (this *mainEngine) AddRedactHeaders(names ...string) {
+	requestLogger := this.requestLogger()
+	requestLogger.lock.Lock()
+	defer requestLogger.lock.Unlock()
+	for _, name := range names {
+		requestLogger.redact[strings.ToLower(name)] = true
+	}
+}
+
+// 获取引擎的请求日志观察者, 不存在时创建
+func (this *mainEngine) requestLogger() *requestLogger {
+	if this.logger == nil {
+		this.logger = newRequestLogger()
+		this.observers = append(this.observers, this.logger)
+	}
+	return this.logger
+}
+
+// 请求日志, 作为观察者记录请求的开始、结束、重试和错误
+type requestLogger struct {
+	lock      sync.RWMutex
+	logger    Logger
+	level     LogLevel
+	bodyLimit int
+	redact    map[string]bool
+}
+
+func newRequestLogger() *requestLogger {
+	return &requestLogger{
+		level:     LevelInfo,
+		bodyLimit: defaultLogBodyLimit,
+		redact: map[string]bool{
+			"authorization":       true,
+			"proxy-authorization": true,
+			"cookie":              true,
+			"set-cookie":          true,
+			"x-api-key":           true,
+		},
+	}
+}
+
+// 按级别输出日志, 低于设置的级别时不输出
+func (this *requestLogger) log(level LogLevel, msg string, args ...interface{}) {
+	switch level {
+	case LevelDebug:
+		this.logger.Debug(msg, args...)
+	case LevelInfo:
+		this.logger.Info(msg, args...)
+	case LevelWarn:
+		this.logger.Warn(msg, args...)
+	default:
+		this.logger.Error(msg, args...)
+	}
+}
+
+func (this *requestLogger) enabled(level LogLevel) bool {
+	return this.logger != nil && level >= this.level
+}
+
+// 是否为需要脱敏的名称, 包含token、secret、password的名称也会脱敏
+func (this *requestLogger) sensitive(name string) bool {
+	name = strings.ToLower(name)
+	if this.redact[name] {
+		return true
+	}
+	for _, keyword := range []string{"token", "secret", "password"} {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// 脱敏请求头
+func (this *requestLogger) headers(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for h, hv := range headers {
+		if this.sensitive(h) {
+			hv = redactedValue
+		}
+		result[h] = hv
+	}
+	return result
+}
+
+// 脱敏URL中的敏感参数和用户密码
+func (this *requestLogger) url(targetUrl string) string {
+	u, err := url.Parse(targetUrl)
+	if err != nil {
+		return targetUrl
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		redacted := false
+		for name := range query {
+			if this.sensitive(name) {
+				query.Set(name, redactedValue)
+				redacted = true
+			}
+		}
+		if redacted {
+			u.RawQuery = query.Encode()
+		}
+	}
+	return u.String()
+}
+
+// 截断请求体, 流式请求体不输出内容
+func (this *requestLogger) body(body interface{}) string {
+	switch b := body.(type) {
+	case nil:
+		return ""
+	case string:
+		return this.truncate(b)
+	case []byte:
+		return this.truncate(string(b))
+	case io.Reader, func() (io.ReadCloser, error):
+		return "<stream>"
+	default:
+		return this.truncate(fmt.Sprintf("%+v", b))
+	}
+}
+
+func (this *requestLogger) truncate(s string) string {
+	if len(s) <= this.bodyLimit {
+		return s
+	}
+	return s[:this.bodyLimit] + "...(" + strconv.Itoa(len(s)) + " bytes)"
+}
+
+// 截断字节形式的响应内容, 只转换保留的部分
+func (this *requestLogger) truncateBytes(b []byte) string {
+	if len(b) <= this.bodyLimit {
+		return string(b)
+	}
+	return string(b[:this.bodyLimit]) + "...(" + strconv.Itoa(len(b)) + " bytes)"
+}
+
+func (this *requestLogger) requestQueued(request *Request) {}
+
+func (this *requestLogger) requestDequeued(request *Request) {}
+
+func (this *requestLogger) requestStarted(request *Request) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	if request.RetryNumber > 0 && this.enabled(LevelInfo) {
+		this.log(LevelInfo, "request retry", "request_id", request.ID, "method", request.Method, "url", this.url(request.URL), "retry", request.RetryNumber)
+	}
+	if !this.enabled(LevelDebug) {
+		return
+	}
+	args := []interface{}{"request_id", request.ID, "method", request.Method, "url", this.url(request.URL), "retry", request.RetryNumber, "headers", this.headers(request.Headers)}
+	if this.bodyLimit > 0 && request.Body != nil {
+		args = append(args, "body", this.body(request.Body))
+	}
+	this.log(LevelDebug, "request started", args...)
+}
+
+func (this *requestLogger) requestFinished(request *Request, response *Response, bytesIn int64, bytesOut int64) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	level := LevelInfo
+	msg := "request finished"
+	var statusErr *HTTPStatusError
+	if errors.As(response.Error, &statusErr) {
+		level = LevelWarn
+		msg = "request status error"
+	} else if response.Error != nil {
+		level = LevelError
+		msg = "request failed"
+	}
+	if !this.enabled(level) {
+		return
+	}
+	timings := response.Timings
+	args := []interface{}{
+		"request_id", request.ID,
+		"method", request.Method,
+		"url", this.url(request.URL),
+		"status", response.StatusCode,
+		"retry", request.RetryNumber,
+		"bytes_in", bytesIn,
+		"bytes_out", bytesOut,
+		"queue", timings.Queue,
+		"dns", timings.DNS,
+		"connect", timings.Connect,
+		"tls", timings.TLSHandshake,
+		"first_byte", timings.FirstByte,
+		"total", timings.Total,
+	}
+	if response.Error != nil {
+		args = append(args, "error", response.Error.Error())
+	}
+	if this.enabled(LevelDebug) && this.bodyLimit > 0 {
+		if statusErr != nil {
+			args = append(args, "body", this.truncate(string(statusErr.Body)))
+		} else if response.Text != "" {
+			args = append(args, "body", this.truncate(response.Text))
+		} else if len(response.Bytes) > 0 {
+			args = append(args, "body", this.truncateBytes(response.Bytes))
+		}
+	}
+	this.log(level, msg, args...)
+}
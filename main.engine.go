@@ -1,35 +1,70 @@
 package gogorequest
 
 import (
+	"context"
 	"crypto/tls"
 	"golang.org/x/net/http2"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"time"
 )
 
 // 主引擎
 type mainEngine struct {
-	transport    *http.Transport
-	WarnerEmail  *warnerEmail
-	WarnerFeiShu *warnerFeiShu
+	transport          *http.Transport
+	progress           ProgressReporter
+	stream             bool
+	maxBodySize        int64
+	spill              bool
+	spillDir           string
+	textOff            bool
+	errorStatus        []StatusRange
+	timeouts           Timeouts
+	stats              *engineStats
+	observers          []observer
+	logger             *requestLogger
+	tracer             Tracer
+	auth               Authenticator
+	requestIDHeader    string
+	requestIDHeaderOff bool
+	envProxy           func(*url.URL) (*url.URL, error)
+	proxyRules         []*proxyRule
+	pac                *proxyPAC
+	dns                *dnsResolver
+	local              *localAddrs
+	unixSocket         string
+	dial               func(ctx context.Context, network string, addr string) (net.Conn, error)
+	proxyTransports    proxyTransports
+	http2              bool
+	WarnerEmail        *warnerEmail
+	WarnerFeiShu       *warnerFeiShu
 }
 
 // 初始化默认transport
 func (this *mainEngine) initTransport() {
 	// 默认transport
 	transport := http.Transport{
-		DialContext:           (&net.Dialer{}).DialContext,
+		DialContext:           this.dialContext,
 		DisableKeepAlives:     false,
 		MaxIdleConns:          100,              // 最大空闲连接数
 		IdleConnTimeout:       60 * time.Second, // 空闲连接超时
 		TLSHandshakeTimeout:   60 * time.Second, // TLS 握手超时
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	this.applyProxy(&transport)
 	this.transport = &transport
 }
 
+// 初始化引擎统计
+func (this *mainEngine) initStats() {
+	this.stats = newEngineStats()
+	this.observers = append(this.observers, this.stats)
+}
+
 // 读取证书
 func (this *mainEngine) ReadCrt(cerPath string, keyPath string) (*tls.Config, error) {
 	cliCrt, err := tls.LoadX509KeyPair(cerPath, keyPath)
@@ -44,6 +79,8 @@ func (this *mainEngine) ReadCrt(cerPath string, keyPath string) (*tls.Config, er
 
 // 重置自定义transport
 func (this *mainEngine) SetTransport(transport *http.Transport) {
+	this.applyTimeouts(transport)
+	this.applyProxy(transport)
 	this.transport = transport
 }
 
@@ -53,21 +90,127 @@ func (this *mainEngine) EnableHTTP2() error {
 	if err != nil {
 		return err
 	}
+	this.http2 = true
+	this.resetCachedTransports()
 	return nil
 }
 
-// 为请求client设置代理IP、transport、请求超时
-func (this *mainEngine) addProxyAndTransport(client *http.Client, proxies string, timeout time.Duration) error {
-	if proxies != "" {
-		proxyUrl, err := url.Parse(proxies)
+// 开启流式响应模式, 响应体不再读入内存, 由调用方读取Body并负责关闭
+func (this *mainEngine) EnableStream() {
+	this.stream = true
+}
+
+// 设置响应体的最大字节数, 超过时返回BodyTooLargeError, 小于等于0表示不限制
+func (this *mainEngine) SetMaxBodySize(size int64) {
+	this.maxBodySize = size
+}
+
+// 响应体超过大小限制时写入dir下的临时文件而不是返回错误, dir为空时使用系统临时目录
+func (this *mainEngine) EnableSpillToDisk(dir string) {
+	this.spill = true
+	this.spillDir = dir
+}
+
+// 响应内容只保存在Bytes中, 不再生成Text, 避免响应内容在内存中保存两份
+func (this *mainEngine) DisableText() {
+	this.textOff = true
+}
+
+// 读取响应体, 超过大小限制时按配置返回错误或落盘, 落盘时返回临时文件路径
+func (this *mainEngine) readBody(res *http.Response) ([]byte, string, error) {
+	if this.maxBodySize <= 0 {
+		data, err := ioutil.ReadAll(res.Body)
+		return data, "", bodyReadError(err)
+	}
+	if res.ContentLength > this.maxBodySize && !this.spill {
+		return nil, "", &BodyTooLargeError{Limit: this.maxBodySize}
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, this.maxBodySize+1))
+	if err != nil {
+		return nil, "", bodyReadError(err)
+	}
+	if int64(len(data)) <= this.maxBodySize {
+		return data, "", nil
+	}
+	if !this.spill {
+		return nil, "", &BodyTooLargeError{Limit: this.maxBodySize}
+	}
+
+	file, createErr := ioutil.TempFile(this.spillDir, "gogorequest-*.body")
+	if createErr != nil {
+		return nil, "", createErr
+	}
+	defer file.Close()
+	_, writeErr := file.Write(data)
+	if writeErr == nil {
+		_, writeErr = io.Copy(file, res.Body)
+	}
+	if writeErr != nil {
+		os.Remove(file.Name())
+		return nil, "", bodyReadError(writeErr)
+	}
+	return nil, file.Name(), nil
+}
+
+// 开启状态码校验, 状态码在ranges范围内的响应视为失败, 不传ranges时默认为400-599
+func (this *mainEngine) EnableStatusCheck(ranges ...StatusRange) {
+	if len(ranges) == 0 {
+		ranges = []StatusRange{{From: 400, To: 599}}
+	}
+	this.errorStatus = ranges
+}
+
+// 设置文件传输的进度回调, 不设置时默认输出到终端
+func (this *mainEngine) SetProgressReporter(reporter ProgressReporter) {
+	this.progress = reporter
+}
+
+// 获取进度回调, action为默认终端输出的动作名称
+func (this *mainEngine) progressReporter(action string) ProgressReporter {
+	if this.progress != nil {
+		return this.progress
+	}
+	return consoleProgress{action: action}
+}
+
+// 为请求client设置transport、请求超时, 返回请求使用的代理, 请求未设置代理时按代理规则和PAC选择
+// HTTP代理通过请求的ctx传给transport, 不修改共用的transport, SOCKS代理使用独立的transport
+func (this *mainEngine) addProxyAndTransport(client *http.Client, request *Request) (*url.URL, error) {
+	// Unix socket请求使用独立的transport, 不使用代理
+	socket, _, isUnix, err := unixRequestURL(request.URL)
+	if err != nil {
+		return nil, err
+	}
+	if !isUnix {
+		socket = this.unixSocket
+	}
+	if socket != "" {
+		transport, err := this.unixTransport(socket)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		this.transport.Proxy = http.ProxyURL(proxyUrl)
+		client.Transport = transport
+		client.Timeout = this.totalTimeout(request.Timeout)
+		return directProxy, nil
 	}
-	client.Transport = this.transport
-	client.Timeout = timeout * time.Second
-	return nil
+
+	proxyUrl, err := parseProxy(request.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if proxyUrl == nil {
+		proxyUrl, err = this.routeProxy(request.context(), request.URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	transport, err := this.transportFor(proxyUrl)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = transport
+	client.Timeout = this.totalTimeout(request.Timeout)
+	return proxyUrl, nil
 }
 
 // 开启邮件报警器
@@ -0,0 +1,263 @@
+package gogorequest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 请求耗时直方图的默认分桶(秒)
+var defaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Prometheus指标采集器, 由引擎在执行请求时更新, 可作为http.Handler挂载到/metrics
+type Metrics struct {
+	namespace string
+	buckets   []float64
+	lock      sync.Mutex
+	inFlight  int64
+	queued    int64
+	requests  map[requestLabels]int64
+	retries   map[requestLabels]int64
+	durations map[requestLabels]*histogram
+	bytesIn   map[string]int64
+	bytesOut  map[string]int64
+	hosts     map[string]bool
+}
+
+// 请求指标的标签
+type requestLabels struct {
+	host        string
+	method      string
+	statusClass string
+}
+
+// 直方图, counts为各分桶的非累计计数
+type histogram struct {
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+// 实例化指标采集器, namespace为指标名前缀, 为空时使用gogorequest
+func NewMetrics(namespace string) *Metrics {
+	if namespace == "" {
+		namespace = "gogorequest"
+	}
+	return &Metrics{
+		namespace: namespace,
+		buckets:   defaultDurationBuckets,
+		requests:  map[requestLabels]int64{},
+		retries:   map[requestLabels]int64{},
+		durations: map[requestLabels]*histogram{},
+		bytesIn:   map[string]int64{},
+		bytesOut:  map[string]int64{},
+		hosts:     map[string]bool{},
+	}
+}
+
+// 设置请求耗时直方图的分桶(秒), 已采集的耗时数据会被清空
+func (this *Metrics) SetDurationBuckets(buckets []float64) {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.buckets = sorted
+	this.durations = map[requestLabels]*histogram{}
+}
+
+// 为引擎开启指标采集, 多个引擎可以共用同一个采集器
+func (this *mainEngine) SetMetrics(metrics *Metrics) {
+	for _, o := range this.observers {
+		if o == metrics {
+			return
+		}
+	}
+	this.observers = append(this.observers, metrics)
+}
+
+func (this *Metrics) requestQueued(request *Request) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.queued += 1
+}
+
+func (this *Metrics) requestDequeued(request *Request) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.queued -= 1
+}
+
+func (this *Metrics) requestStarted(request *Request) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.inFlight += 1
+	if request.RetryNumber > 0 {
+		this.retries[requestLabels{host: this.hostLabel(request), method: request.Method}] += 1
+	}
+}
+
+func (this *Metrics) requestFinished(request *Request, response *Response, bytesIn int64, bytesOut int64) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	host := this.hostLabel(request)
+	this.inFlight -= 1
+	this.requests[requestLabels{host: host, method: request.Method, statusClass: statusClass(response.StatusCode)}] += 1
+
+	durationLabels := requestLabels{host: host, method: request.Method}
+	h, ok := this.durations[durationLabels]
+	if !ok {
+		h = &histogram{counts: make([]int64, len(this.buckets))}
+		this.durations[durationLabels] = h
+	}
+	seconds := response.Timings.Total.Seconds()
+	for i, bound := range this.buckets {
+		if seconds <= bound {
+			h.counts[i] += 1
+			break
+		}
+	}
+	h.sum += seconds
+	h.count += 1
+
+	this.bytesIn[host] += bytesIn
+	this.bytesOut[host] += bytesOut
+}
+
+// 请求的host标签, 域名数达到上限后新出现的域名使用otherHost, 避免指标序列无限增长, 调用方需持有锁
+func (this *Metrics) hostLabel(request *Request) string {
+	host := requestHost(request.URL)
+	if this.hosts[host] {
+		return host
+	}
+	if len(this.hosts) >= maxTrackedHosts {
+		return otherHost
+	}
+	this.hosts[host] = true
+	return host
+}
+
+// 状态码分类, 没有收到响应时为error
+func statusClass(statusCode int) string {
+	if statusCode <= 0 {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
+
+// 按Prometheus文本格式输出指标
+func (this *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(this.render())
+}
+
+func (this *Metrics) render() []byte {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	var buf bytes.Buffer
+
+	name := this.namespace + "_requests_total"
+	writeHeader(&buf, name, "counter", "已完成的请求数")
+	for _, labels := range sortedLabels(this.requests) {
+		writeSample(&buf, name, labelPairs("host", labels.host, "method", labels.method, "status_class", labels.statusClass), float64(this.requests[labels]))
+	}
+
+	name = this.namespace + "_request_retries_total"
+	writeHeader(&buf, name, "counter", "重试的请求数")
+	for _, labels := range sortedLabels(this.retries) {
+		writeSample(&buf, name, labelPairs("host", labels.host, "method", labels.method), float64(this.retries[labels]))
+	}
+
+	name = this.namespace + "_request_duration_seconds"
+	writeHeader(&buf, name, "histogram", "请求耗时, 不含排队时间")
+	durationLabels := make([]requestLabels, 0, len(this.durations))
+	for labels := range this.durations {
+		durationLabels = append(durationLabels, labels)
+	}
+	sortLabels(durationLabels)
+	for _, labels := range durationLabels {
+		h := this.durations[labels]
+		var cumulative int64 = 0
+		for i, bound := range this.buckets {
+			cumulative += h.counts[i]
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			writeSample(&buf, name+"_bucket", labelPairs("host", labels.host, "method", labels.method, "le", le), float64(cumulative))
+		}
+		writeSample(&buf, name+"_bucket", labelPairs("host", labels.host, "method", labels.method, "le", "+Inf"), float64(h.count))
+		writeSample(&buf, name+"_sum", labelPairs("host", labels.host, "method", labels.method), h.sum)
+		writeSample(&buf, name+"_count", labelPairs("host", labels.host, "method", labels.method), float64(h.count))
+	}
+
+	name = this.namespace + "_requests_in_flight"
+	writeHeader(&buf, name, "gauge", "正在执行的请求数")
+	writeSample(&buf, name, "", float64(this.inFlight))
+
+	name = this.namespace + "_queue_depth"
+	writeHeader(&buf, name, "gauge", "等待执行的请求数")
+	writeSample(&buf, name, "", float64(this.queued))
+
+	name = this.namespace + "_response_bytes_total"
+	writeHeader(&buf, name, "counter", "接收的响应体字节数, 包含文件下载")
+	for _, host := range sortedHosts(this.bytesIn) {
+		writeSample(&buf, name, labelPairs("host", host), float64(this.bytesIn[host]))
+	}
+
+	name = this.namespace + "_request_bytes_total"
+	writeHeader(&buf, name, "counter", "发送的请求体字节数, 包含文件上传")
+	for _, host := range sortedHosts(this.bytesOut) {
+		writeSample(&buf, name, labelPairs("host", host), float64(this.bytesOut[host]))
+	}
+	return buf.Bytes()
+}
+
+func writeHeader(buf *bytes.Buffer, name string, metricType string, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, metricType)
+}
+
+func writeSample(buf *bytes.Buffer, name string, labels string, value float64) {
+	fmt.Fprintf(buf, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// 生成{k="v",...}形式的标签, 转义反斜杠、双引号和换行
+func labelPairs(pairs ...string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], replacer.Replace(pairs[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func sortedLabels(values map[requestLabels]int64) []requestLabels {
+	labels := make([]requestLabels, 0, len(values))
+	for l := range values {
+		labels = append(labels, l)
+	}
+	sortLabels(labels)
+	return labels
+}
+
+func sortLabels(labels []requestLabels) {
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].host != labels[j].host {
+			return labels[i].host < labels[j].host
+		}
+		if labels[i].method != labels[j].method {
+			return labels[i].method < labels[j].method
+		}
+		return labels[i].statusClass < labels[j].statusClass
+	})
+}
+
+func sortedHosts(values map[string]int64) []string {
+	hosts := make([]string, 0, len(values))
+	for host := range values {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
@@ -0,0 +1,193 @@
+package gogorequest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认在令牌过期前30秒刷新
+const defaultOAuth2ExpiryDelta = 30 * time.Second
+
+// OAuth2配置
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Params       map[string]string // 获取令牌时的额外参数, 如audience
+	AuthInParams bool              // 为true时client_id和client_secret放在请求参数中, 否则使用Basic认证
+	ExpiryDelta  time.Duration     // 提前刷新令牌的时间, 为0时使用30秒
+	HTTPClient   *http.Client      // 获取令牌使用的客户端, 为nil时使用30秒超时的默认客户端
+}
+
+// OAuth2令牌
+type OAuth2Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time // 过期时间, 服务端未返回expires_in时为零值, 表示不过期
+}
+
+// OAuth2令牌源, 缓存令牌并在过期前刷新, 可作为引擎或请求的认证方式
+// 收到401响应时强制刷新令牌并重新发送一次请求
+type OAuth2TokenSource struct {
+	config       OAuth2Config
+	grantType    string
+	refreshToken string
+	lock         sync.Mutex
+	token        *OAuth2Token
+}
+
+// 实例化client_credentials授权的令牌源
+func NewOAuth2ClientCredentials(config OAuth2Config) *OAuth2TokenSource {
+	return &OAuth2TokenSource{config: config, grantType: "client_credentials"}
+}
+
+// 实例化refresh_token授权的令牌源, 服务端返回新的refresh_token时自动替换
+func NewOAuth2RefreshToken(config OAuth2Config, refreshToken string) *OAuth2TokenSource {
+	return &OAuth2TokenSource{config: config, grantType: "refresh_token", refreshToken: refreshToken}
+}
+
+// 获取令牌, 缓存的令牌即将过期时重新获取, 并发调用时只获取一次
+func (this *OAuth2TokenSource) Token() (*OAuth2Token, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.token != nil && !this.expired(this.token) {
+		return this.token, nil
+	}
+	token, err := this.fetch()
+	if err != nil {
+		return nil, err
+	}
+	this.token = token
+	return token, nil
+}
+
+func (this *OAuth2TokenSource) expired(token *OAuth2Token) bool {
+	if token.Expiry.IsZero() {
+		return false
+	}
+	delta := this.config.ExpiryDelta
+	if delta == 0 {
+		delta = defaultOAuth2ExpiryDelta
+	}
+	return time.Now().Add(delta).After(token.Expiry)
+}
+
+// 令牌接口的响应
+type oauth2TokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	TokenType        string      `json:"token_type"`
+	RefreshToken     string      `json:"refresh_token"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+// 请求令牌接口, 调用方需持有锁
+func (this *OAuth2TokenSource) fetch() (*OAuth2Token, error) {
+	params := url.Values{}
+	params.Set("grant_type", this.grantType)
+	if this.grantType == "refresh_token" {
+		params.Set("refresh_token", this.refreshToken)
+	}
+	if len(this.config.Scopes) > 0 {
+		params.Set("scope", strings.Join(this.config.Scopes, " "))
+	}
+	for k, v := range this.config.Params {
+		params.Set(k, v)
+	}
+	if this.config.AuthInParams {
+		params.Set("client_id", this.config.ClientID)
+		if this.config.ClientSecret != "" {
+			params.Set("client_secret", this.config.ClientSecret)
+		}
+	}
+
+	req, newRequestErr := http.NewRequest("POST", this.config.TokenURL, strings.NewReader(params.Encode()))
+	if newRequestErr != nil {
+		return nil, fmt.Errorf("获取OAuth2令牌失败: %w", newRequestErr)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !this.config.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(this.config.ClientID), url.QueryEscape(this.config.ClientSecret))
+	}
+
+	client := this.config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	res, doErr := client.Do(req)
+	if doErr != nil {
+		return nil, fmt.Errorf("获取OAuth2令牌失败: %w", classifyError(doErr))
+	}
+	defer res.Body.Close()
+	body, readErr := ioutil.ReadAll(res.Body)
+	if readErr != nil {
+		return nil, fmt.Errorf("获取OAuth2令牌失败: %w", bodyReadError(readErr))
+	}
+
+	var tokenResponse oauth2TokenResponse
+	unmarshalErr := json.Unmarshal(body, &tokenResponse)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		statusErr := &HTTPStatusError{StatusCode: res.StatusCode, Status: res.Status, Body: body}
+		if unmarshalErr == nil && tokenResponse.Error != "" {
+			return nil, fmt.Errorf("获取OAuth2令牌失败: %s %s: %w", tokenResponse.Error, tokenResponse.ErrorDescription, statusErr)
+		}
+		return nil, fmt.Errorf("获取OAuth2令牌失败: %w", statusErr)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("获取OAuth2令牌失败: %w", unmarshalErr)
+	}
+	if tokenResponse.AccessToken == "" {
+		return nil, fmt.Errorf("获取OAuth2令牌失败: 服务端未返回access_token")
+	}
+
+	token := &OAuth2Token{
+		AccessToken:  tokenResponse.AccessToken,
+		TokenType:    tokenResponse.TokenType,
+		RefreshToken: tokenResponse.RefreshToken,
+	}
+	if expiresIn, err := tokenResponse.ExpiresIn.Int64(); err == nil && expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	if token.RefreshToken != "" {
+		this.refreshToken = token.RefreshToken
+	}
+	return token, nil
+}
+
+// 添加Authorization请求头
+func (this *OAuth2TokenSource) Authenticate(req *http.Request) error {
+	token, err := this.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", oauth2Authorization(token))
+	return nil
+}
+
+// 收到401响应时作废请求使用的令牌, 并发请求同时收到401时只刷新一次
+func (this *OAuth2TokenSource) Challenge(req *http.Request, res *http.Response) (bool, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.token != nil && req.Header.Get("Authorization") == oauth2Authorization(this.token) {
+		this.token = nil
+	}
+	return true, nil
+}
+
+func oauth2Authorization(token *OAuth2Token) string {
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + token.AccessToken
+}
@@ -0,0 +1,98 @@
+package gogorequest
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// 单个请求的可选配置
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	expectStatus []int
+	errorStatus  []StatusRange
+	ctx          context.Context
+	requestID    string
+	auth         Authenticator
+	authSet      bool
+}
+
+// 生成请求配置, 没有配置项时返回nil
+func newRequestOptions(opts []RequestOption) *requestOptions {
+	if len(opts) == 0 {
+		return nil
+	}
+	options := &requestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// 状态码范围, 包含From和To
+type StatusRange struct {
+	From int
+	To   int
+}
+
+func (this StatusRange) contains(statusCode int) bool {
+	return statusCode >= this.From && statusCode <= this.To
+}
+
+// 声明请求期望的状态码, 响应状态码不在其中时视为失败, 优先于引擎的状态码校验
+func WithExpectStatus(statusCodes ...int) RequestOption {
+	return func(options *requestOptions) {
+		options.expectStatus = statusCodes
+	}
+}
+
+// 设置当前请求视为失败的状态码范围, 覆盖引擎的状态码校验配置
+func WithErrorStatus(ranges ...StatusRange) RequestOption {
+	return func(options *requestOptions) {
+		options.errorStatus = ranges
+	}
+}
+
+// 设置请求的ctx, 用于取消请求和传递链路追踪信息, 重试时沿用
+func WithContext(ctx context.Context) RequestOption {
+	return func(options *requestOptions) {
+		options.ctx = ctx
+	}
+}
+
+// 校验响应状态码, 校验失败时返回HTTPStatusError, 响应内容由调用方填充
+func (this *mainEngine) checkStatus(res *http.Response, options *requestOptions) *HTTPStatusError {
+	statusErr := &HTTPStatusError{StatusCode: res.StatusCode, Status: res.Status}
+	errorStatus := this.errorStatus
+	if options != nil {
+		if len(options.expectStatus) > 0 {
+			for _, statusCode := range options.expectStatus {
+				if statusCode == res.StatusCode {
+					return nil
+				}
+			}
+			return statusErr
+		}
+		if options.errorStatus != nil {
+			errorStatus = options.errorStatus
+		}
+	}
+	for _, statusRange := range errorStatus {
+		if statusRange.contains(res.StatusCode) {
+			return statusErr
+		}
+	}
+	return nil
+}
+
+// 读取状态码校验失败的响应内容, 设置了大小限制时最多读取限制的字节数
+func (this *mainEngine) readErrorBody(res *http.Response) []byte {
+	var reader io.Reader = res.Body
+	if this.maxBodySize > 0 {
+		reader = io.LimitReader(res.Body, this.maxBodySize)
+	}
+	body, _ := ioutil.ReadAll(reader)
+	return body
+}
@@ -0,0 +1,119 @@
+package gogorequest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// 请求体, 长度未知时为-1, 以chunked方式发送
+type requestPayload struct {
+	reader  io.Reader
+	length  int64
+	getBody func() (io.ReadCloser, error)
+}
+
+// 生成请求体:
+// string、[]byte原样发送;
+// io.Reader流式发送, 只能读取一次, 重试时无法重放;
+// func() (io.ReadCloser, error)每次请求调用一次, 可用于重试时重放请求体;
+// 其他类型按请求头声明的Content-Type编码, 未声明或未注册的类型按JSON编码
+func encodeBody(body interface{}, headers map[string]string) (*requestPayload, error) {
+	switch requestBody := body.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return &requestPayload{reader: strings.NewReader(requestBody), length: int64(len(requestBody))}, nil
+	case []byte:
+		return &requestPayload{reader: bytes.NewReader(requestBody), length: int64(len(requestBody))}, nil
+	case func() (io.ReadCloser, error):
+		reader, openErr := requestBody()
+		if openErr != nil {
+			return nil, wrapError(ErrEncode, openErr)
+		}
+		return &requestPayload{reader: reader, length: readerLength(reader), getBody: requestBody}, nil
+	case io.Reader:
+		return &requestPayload{reader: requestBody, length: readerLength(requestBody)}, nil
+	}
+	codec, ok := getCodec(headerContentType(headers))
+	if !ok {
+		codec = jsonCodec{}
+	}
+	data, marshalErr := codec.Marshal(body)
+	if marshalErr != nil {
+		return nil, wrapError(ErrEncode, marshalErr)
+	}
+	return &requestPayload{reader: bytes.NewReader(data), length: int64(len(data))}, nil
+}
+
+// 获取reader剩余的可读长度, 无法确定时返回-1
+func readerLength(reader io.Reader) int64 {
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case *os.File:
+		stat, statErr := r.Stat()
+		if statErr != nil || !stat.Mode().IsRegular() {
+			return -1
+		}
+		offset, seekErr := r.Seek(0, io.SeekCurrent)
+		if seekErr != nil {
+			return -1
+		}
+		return stat.Size() - offset
+	}
+	return -1
+}
+
+// 包装请求, 长度已知时设置Content-Length, 否则使用chunked传输
+func newRequest(method string, targetUrl string, payload *requestPayload) (*http.Request, error) {
+	if payload == nil {
+		return http.NewRequest(method, targetUrl, nil)
+	}
+	req, newRequestErr := http.NewRequest(method, targetUrl, payload.reader)
+	if newRequestErr != nil {
+		return nil, newRequestErr
+	}
+	if payload.length == 0 {
+		req.Body = http.NoBody
+	}
+	req.ContentLength = payload.length
+	if payload.getBody != nil {
+		req.GetBody = payload.getBody
+	}
+	return req, nil
+}
+
+// 统计已读取字节数的reader
+type countingReader struct {
+	reader io.Reader
+	count  *int64
+}
+
+func (this *countingReader) Read(p []byte) (int, error) {
+	n, err := this.reader.Read(p)
+	atomic.AddInt64(this.count, int64(n))
+	return n, err
+}
+
+// 统计已读取字节数并转发Close的reader, 使transport发送完毕后能关闭调用方传入的请求体
+type countingReadCloser struct {
+	countingReader
+	closer io.Closer
+}
+
+func (this *countingReadCloser) Close() error {
+	return this.closer.Close()
+}
+
+// 包装reader统计已读取字节数, reader实现了io.Closer时保留Close
+func newCountingReader(reader io.Reader, count *int64) io.Reader {
+	counter := countingReader{reader: reader, count: count}
+	if closer, ok := reader.(io.Closer); ok {
+		return &countingReadCloser{countingReader: counter, closer: closer}
+	}
+	return &counter
+}
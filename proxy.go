@@ -0,0 +1,221 @@
+package gogorequest
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/proxy"
+)
+
+// 缓存的SOCKS代理、Unix socket的transport数量上限, 超过时关闭最久未使用的transport的空闲连接并移出缓存
+const maxProxyTransports = 64
+
+type proxyContextKey struct{}
+
+// SOCKS代理、Unix socket的transport缓存, 每个代理或socket使用独立的连接池
+// base变化或引擎的transport配置变化时重新生成
+type proxyTransports struct {
+	lock       sync.Mutex
+	base       *http.Transport
+	transports map[string]*list.Element
+	order      *list.List // 按最近使用排列, 最近使用的在前
+}
+
+type cachedTransportEntry struct {
+	key       string
+	transport *http.Transport
+}
+
+// 开启环境变量代理模式, 未设置代理的请求按HTTP_PROXY、HTTPS_PROXY、NO_PROXY选择代理
+func (this *mainEngine) EnableEnvironmentProxy() {
+	this.envProxy = httpproxy.FromEnvironment().ProxyFunc()
+}
+
+// 解析代理地址, 支持http、https、socks5、socks5h和DIRECT, 省略协议时为http
+func parseProxy(proxies string) (*url.URL, error) {
+	if proxies == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(proxies, ProxyDirect) {
+		return directProxy, nil
+	}
+	if !strings.Contains(proxies, "://") {
+		proxies = "http://" + proxies
+	}
+	proxyUrl, err := url.Parse(proxies)
+	if err != nil {
+		return nil, wrapError(ErrProxy, err)
+	}
+	switch proxyUrl.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, wrapError(ErrProxy, fmt.Errorf("不支持的代理协议: %s", proxyUrl.Scheme))
+	}
+	if proxyUrl.Host == "" {
+		return nil, wrapError(ErrProxy, fmt.Errorf("代理地址缺少主机: %s", proxies))
+	}
+	return proxyUrl, nil
+}
+
+func isSocksProxy(proxyUrl *url.URL) bool {
+	return proxyUrl != nil && (proxyUrl.Scheme == "socks5" || proxyUrl.Scheme == "socks5h")
+}
+
+// 将HTTP代理保存到请求的ctx中, 由transport的Proxy读取
+func withProxy(ctx context.Context, proxyUrl *url.URL) context.Context {
+	if proxyUrl == nil || isSocksProxy(proxyUrl) {
+		return ctx
+	}
+	return context.WithValue(ctx, proxyContextKey{}, proxyUrl)
+}
+
+// 为transport设置按请求选择代理的Proxy, transport原有的Proxy在请求没有代理时使用
+func (this *mainEngine) applyProxy(transport *http.Transport) {
+	fallback := transport.Proxy
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxyUrl, ok := req.Context().Value(proxyContextKey{}).(*url.URL); ok {
+			if proxyUrl == directProxy {
+				return nil, nil
+			}
+			return proxyUrl, nil
+		}
+		if this.envProxy != nil {
+			return this.envProxy(req.URL)
+		}
+		if fallback != nil {
+			return fallback(req)
+		}
+		return nil, nil
+	}
+}
+
+// 获取请求使用的transport, SOCKS代理使用独立的transport, 其他请求共用引擎的transport
+func (this *mainEngine) transportFor(proxyUrl *url.URL) (*http.Transport, error) {
+	if !isSocksProxy(proxyUrl) {
+		return this.transport, nil
+	}
+	return this.cachedTransport(proxyUrl.String(), func() (*http.Transport, error) {
+		return this.newSocksTransport(proxyUrl)
+	})
+}
+
+// 从缓存获取独立的transport, 不存在时通过build生成, 缓存已满时移出最久未使用的transport
+// 引擎的transport变化后全部重新生成
+func (this *mainEngine) cachedTransport(key string, build func() (*http.Transport, error)) (*http.Transport, error) {
+	cache := &this.proxyTransports
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if cache.base != this.transport || cache.transports == nil {
+		cache.reset()
+		cache.base = this.transport
+	}
+	if element, ok := cache.transports[key]; ok {
+		cache.order.MoveToFront(element)
+		return element.Value.(*cachedTransportEntry).transport, nil
+	}
+	transport, err := build()
+	if err != nil {
+		return nil, err
+	}
+	for cache.order.Len() >= maxProxyTransports {
+		oldest := cache.order.Back()
+		entry := cache.order.Remove(oldest).(*cachedTransportEntry)
+		delete(cache.transports, entry.key)
+		entry.transport.CloseIdleConnections()
+	}
+	cache.transports[key] = cache.order.PushFront(&cachedTransportEntry{key: key, transport: transport})
+	return transport, nil
+}
+
+// 引擎的transport配置变化后清空缓存, 之后的请求基于新配置重新生成
+func (this *mainEngine) resetCachedTransports() {
+	this.proxyTransports.lock.Lock()
+	defer this.proxyTransports.lock.Unlock()
+	this.proxyTransports.reset()
+}
+
+// 关闭缓存的transport的空闲连接并清空缓存, 调用方需持有锁
+func (this *proxyTransports) reset() {
+	for _, element := range this.transports {
+		element.Value.(*cachedTransportEntry).transport.CloseIdleConnections()
+	}
+	this.transports = map[string]*list.Element{}
+	this.order = list.New()
+}
+
+// 基于引擎的transport生成通过SOCKS代理连接的transport
+func (this *mainEngine) newSocksTransport(proxyUrl *url.URL) (*http.Transport, error) {
+	var auth *proxy.Auth
+	if proxyUrl.User != nil {
+		password, _ := proxyUrl.User.Password()
+		auth = &proxy.Auth{User: proxyUrl.User.Username(), Password: password}
+	}
+	forward := this.transport.DialContext
+	if forward == nil {
+		forward = this.dialContext
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyUrl.Host, auth, dialFunc(forward))
+	if err != nil {
+		return nil, wrapError(ErrProxy, err)
+	}
+	socks := &socksDialer{dialer: dialer.(proxy.ContextDialer)}
+	if proxyUrl.Scheme == "socks5" {
+		socks.lookupIP = this.lookupIP
+	}
+
+	transport := this.transport.Clone()
+	transport.Proxy = nil
+	transport.DialContext = socks.DialContext
+	if this.http2 {
+		transport.TLSNextProto = nil
+		if err := http2.ConfigureTransport(transport); err != nil {
+			return nil, err
+		}
+	}
+	return transport, nil
+}
+
+// 通过SOCKS代理建立连接, socks5在本地解析域名, socks5h由代理解析域名
+type socksDialer struct {
+	dialer   proxy.ContextDialer
+	lookupIP func(ctx context.Context, host string) ([]net.IP, error) // 为nil时由代理解析域名
+}
+
+func (this *socksDialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	if this.lookupIP != nil {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) == nil {
+			ips, lookupErr := this.lookupIP(ctx, host)
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+			if len(ips) == 0 {
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			}
+			addr = net.JoinHostPort(ips[0].String(), port)
+		}
+	}
+	return this.dialer.DialContext(ctx, network, addr)
+}
+
+// 将DialContext函数包装为proxy.Dialer
+type dialFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+func (this dialFunc) Dial(network string, addr string) (net.Conn, error) {
+	return this(context.Background(), network, addr)
+}
+
+func (this dialFunc) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	return this(ctx, network, addr)
+}
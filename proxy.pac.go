@@ -0,0 +1,306 @@
+package gogorequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// PAC脚本单次执行的超时时间, 避免脚本死循环阻塞所有请求
+const pacTimeout = 3 * time.Second
+
+var errPACTimeout = errors.New("PAC脚本执行超时")
+
+// dnsResolve的结果按主机缓存的时间和最大条数, 避免每次执行脚本都在锁内解析域名
+const (
+	pacDNSCacheTTL     = time.Minute
+	maxPACDNSCacheSize = 1024
+)
+
+// PAC脚本的辅助函数, dnsResolve、myIpAddress和shExpMatch由Go实现
+const pacHelpers = `
+function isPlainHostName(host) { return host.indexOf(".") < 0; }
+function dnsDomainIs(host, domain) {
+	return host.length >= domain.length && host.substring(host.length - domain.length) == domain;
+}
+function localHostOrDomainIs(host, hostdom) {
+	return host == hostdom || hostdom.lastIndexOf(host + ".", 0) == 0;
+}
+function isResolvable(host) { return dnsResolve(host) != null; }
+function dnsDomainLevels(host) { return host.split(".").length - 1; }
+function convert_addr(ipchars) {
+	var bytes = ipchars.split(".");
+	return ((bytes[0] & 0xff) << 24) | ((bytes[1] & 0xff) << 16) | ((bytes[2] & 0xff) << 8) | (bytes[3] & 0xff);
+}
+function isInNet(host, pattern, mask) {
+	var ip = /^\d+\.\d+\.\d+\.\d+$/.test(host) ? host : dnsResolve(host);
+	if (ip == null) { return false; }
+	return (convert_addr(ip) & convert_addr(mask)) == (convert_addr(pattern) & convert_addr(mask));
+}
+function __pacNow(args) {
+	var now = new Date();
+	if (args.length > 0 && args[args.length - 1] == "GMT") {
+		Array.prototype.pop.call(args);
+		return {day: now.getUTCDate(), month: now.getUTCMonth(), year: now.getUTCFullYear(), weekday: now.getUTCDay(),
+			hour: now.getUTCHours(), minute: now.getUTCMinutes(), second: now.getUTCSeconds()};
+	}
+	return {day: now.getDate(), month: now.getMonth(), year: now.getFullYear(), weekday: now.getDay(),
+		hour: now.getHours(), minute: now.getMinutes(), second: now.getSeconds()};
+}
+function __pacInRange(value, start, end) {
+	return start <= end ? value >= start && value <= end : value >= start || value <= end;
+}
+function weekdayRange() {
+	var args = Array.prototype.slice.call(arguments);
+	var now = __pacNow(args);
+	var days = ["SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"];
+	var start = days.indexOf(args[0]);
+	var end = args.length > 1 ? days.indexOf(args[1]) : start;
+	return __pacInRange(now.weekday, start, end);
+}
+function dateRange() {
+	var args = Array.prototype.slice.call(arguments);
+	var now = __pacNow(args);
+	var months = ["JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"];
+	var parse = function(values) {
+		var date = {};
+		for (var i = 0; i < values.length; i++) {
+			var month = months.indexOf(values[i]);
+			if (month >= 0) { date.month = month; }
+			else if (values[i] > 31) { date.year = values[i]; }
+			else { date.day = values[i]; }
+		}
+		return date;
+	};
+	var value = function(date, fields) {
+		return (fields.year ? date.year * 10000 : 0) + (fields.month !== undefined ? date.month * 100 : 0) + (fields.day ? date.day : 0);
+	};
+	if (args.length == 1) {
+		var only = parse(args);
+		return value(now, only) == value(only, only);
+	}
+	var start = parse(args.slice(0, args.length / 2));
+	var end = parse(args.slice(args.length / 2));
+	return __pacInRange(value(now, start), value(start, start), value(end, start));
+}
+function timeRange() {
+	var args = Array.prototype.slice.call(arguments);
+	var now = __pacNow(args);
+	var seconds = now.hour * 3600 + now.minute * 60 + now.second;
+	if (args.length == 1) { return now.hour == args[0]; }
+	var n = args.length / 2;
+	var start = args[0] * 3600 + (n > 1 ? args[1] * 60 : 0) + (n > 2 ? args[2] : 0);
+	var end = args[n] * 3600 + (n > 1 ? args[n + 1] * 60 : 0) + (n > 2 ? args[n + 2] : 0);
+	if (n == 1) { end += 3599; }
+	return __pacInRange(seconds, start, end);
+}
+`
+
+// PAC脚本, otto虚拟机不能并发执行, 通过锁串行调用
+// 脚本每次都以完整的URL执行, 只有dnsResolve的结果按主机缓存, dnsCache只在执行脚本时访问, 由lock保护
+type proxyPAC struct {
+	lock     sync.Mutex
+	vm       *otto.Otto
+	lookupIP func(context.Context, string) ([]net.IP, error)
+	dnsCache map[string]pacDNSCacheEntry
+	deadline time.Time // 本次执行的截止时间, otto的中断不能打断域名解析, dnsResolve通过ctx限制解析时间
+}
+
+// 缓存的dnsResolve结果, ip为空表示无法解析
+type pacDNSCacheEntry struct {
+	ip     string
+	expiry time.Time
+}
+
+// 设置PAC脚本, 未设置代理且不匹配代理规则的请求调用脚本的FindProxyForURL选择代理
+// 结果有多个时使用第一个支持的代理, SOCKS代理由代理解析域名
+func (this *mainEngine) SetProxyPAC(script string) error {
+	pac, err := newProxyPAC(script, this.lookupIP)
+	if err != nil {
+		return err
+	}
+	this.pac = pac
+	return nil
+}
+
+// 从文件读取PAC脚本
+func (this *mainEngine) SetProxyPACFile(path string) error {
+	script, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return this.SetProxyPAC(string(script))
+}
+
+func newProxyPAC(script string, lookupIP func(context.Context, string) ([]net.IP, error)) (*proxyPAC, error) {
+	vm := otto.New()
+	pac := &proxyPAC{vm: vm, lookupIP: lookupIP, dnsCache: map[string]pacDNSCacheEntry{}}
+	vm.Set("dnsResolve", pac.dnsResolve)
+	vm.Set("myIpAddress", pacMyIPAddress)
+	vm.Set("shExpMatch", pacShExpMatch)
+	if _, err := vm.Run(pacHelpers); err != nil {
+		return nil, fmt.Errorf("加载PAC辅助函数失败: %w", err)
+	}
+	if _, err := vm.Run(script); err != nil {
+		return nil, fmt.Errorf("PAC脚本无效: %w", err)
+	}
+	if fn, _ := vm.Get("FindProxyForURL"); !fn.IsFunction() {
+		return nil, errors.New("PAC脚本缺少FindProxyForURL函数")
+	}
+	vm.Interrupt = make(chan func(), 1)
+	return pac, nil
+}
+
+// 执行FindProxyForURL并解析结果
+func (this *proxyPAC) findProxy(target *url.URL) (*url.URL, error) {
+	result, err := this.call(target.String(), target.Hostname())
+	if err != nil {
+		return nil, wrapError(ErrProxy, err)
+	}
+	return parsePACResult(result)
+}
+
+func (this *proxyPAC) call(targetUrl string, host string) (result string, err error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.deadline = time.Now().Add(pacTimeout)
+	// 超时回调和结束清理通过本次调用的锁互斥, 调用结束后超时回调不再发出中断, 不会影响下一次调用
+	var interruptLock sync.Mutex
+	finished := false
+	timer := time.AfterFunc(pacTimeout, func() {
+		interruptLock.Lock()
+		defer interruptLock.Unlock()
+		if finished {
+			return
+		}
+		this.vm.Interrupt <- func() {
+			panic(errPACTimeout)
+		}
+	})
+	defer func() {
+		timer.Stop()
+		// 标记结束并清除已发出但未执行的中断
+		interruptLock.Lock()
+		finished = true
+		select {
+		case <-this.vm.Interrupt:
+		default:
+		}
+		interruptLock.Unlock()
+		if caught := recover(); caught != nil {
+			if caught != errPACTimeout {
+				panic(caught)
+			}
+			err = errPACTimeout
+		}
+	}()
+	value, callErr := this.vm.Call("FindProxyForURL", nil, targetUrl, host)
+	if callErr != nil {
+		return "", fmt.Errorf("PAC脚本执行失败: %w", callErr)
+	}
+	return value.String(), nil
+}
+
+// 解析PAC结果, 如"PROXY a:8080; SOCKS5 b:1080; DIRECT", 使用第一个支持的代理
+func parsePACResult(result string) (*url.URL, error) {
+	for _, entry := range strings.Split(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		var scheme string
+		switch strings.ToUpper(fields[0]) {
+		case "DIRECT":
+			return directProxy, nil
+		case "PROXY", "HTTP":
+			scheme = "http"
+		case "HTTPS":
+			scheme = "https"
+		case "SOCKS", "SOCKS5":
+			scheme = "socks5h"
+		default:
+			continue
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		return parseProxy(scheme + "://" + fields[1])
+	}
+	return nil, wrapError(ErrProxy, fmt.Errorf("PAC结果中没有支持的代理: %s", result))
+}
+
+// 解析主机的IPv4地址, 失败时返回null, 使用引擎的域名解析配置, 结果缓存pacDNSCacheTTL
+func (this *proxyPAC) dnsResolve(call otto.FunctionCall) otto.Value {
+	host := strings.ToLower(call.Argument(0).String())
+	now := time.Now()
+	entry, ok := this.dnsCache[host]
+	if !ok || !now.Before(entry.expiry) {
+		// 加载脚本时没有截止时间, 同样最多解析pacTimeout
+		deadline := this.deadline
+		if deadline.IsZero() {
+			deadline = now.Add(pacTimeout)
+		}
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		entry = pacDNSCacheEntry{expiry: now.Add(pacDNSCacheTTL)}
+		if addrs, err := this.lookupIP(ctx, host); err == nil {
+			for _, addr := range addrs {
+				if ip := addr.To4(); ip != nil {
+					entry.ip = ip.String()
+					break
+				}
+			}
+		}
+		// 超时的解析不缓存, 下次执行时重新解析
+		if ctx.Err() == nil {
+			this.storeDNS(host, entry)
+		}
+	}
+	if entry.ip == "" {
+		return otto.NullValue()
+	}
+	value, _ := otto.ToValue(entry.ip)
+	return value
+}
+
+// 缓存dnsResolve的结果, 达到最大条数时先清除过期的结果, 仍然没有空间时清空缓存
+func (this *proxyPAC) storeDNS(host string, entry pacDNSCacheEntry) {
+	if len(this.dnsCache) >= maxPACDNSCacheSize {
+		now := time.Now()
+		for k, cached := range this.dnsCache {
+			if !now.Before(cached.expiry) {
+				delete(this.dnsCache, k)
+			}
+		}
+		if len(this.dnsCache) >= maxPACDNSCacheSize {
+			this.dnsCache = map[string]pacDNSCacheEntry{}
+		}
+	}
+	this.dnsCache[host] = entry
+}
+
+// 本机出口IPv4地址, 通过UDP连接获取, 不发送数据
+func pacMyIPAddress(call otto.FunctionCall) otto.Value {
+	address := "127.0.0.1"
+	if conn, err := net.Dial("udp4", "8.8.8.8:53"); err == nil {
+		address = conn.LocalAddr().(*net.UDPAddr).IP.String()
+		conn.Close()
+	}
+	value, _ := otto.ToValue(address)
+	return value
+}
+
+// 通配符匹配, *匹配任意字符, ?匹配单个字符
+func pacShExpMatch(call otto.FunctionCall) otto.Value {
+	matched := globRegexp(call.Argument(1).String()).MatchString(call.Argument(0).String())
+	value, _ := otto.ToValue(matched)
+	return value
+}
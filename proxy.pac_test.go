@@ -0,0 +1,106 @@
+package gogorequest
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func findPACProxy(t *testing.T, pac *proxyPAC, target string) *url.URL {
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyUrl, err := pac.findProxy(u)
+	if err != nil {
+		t.Fatalf("%s: %v", target, err)
+	}
+	return proxyUrl
+}
+
+func TestProxyPACUsesFullURL(t *testing.T) {
+	script := `function FindProxyForURL(url, host) {
+		if (shExpMatch(url, "*/internal/*")) { return "DIRECT"; }
+		return "PROXY proxy.example.com:8080";
+	}`
+	pac, err := newProxyPAC(script, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一主机的不同路径需要分别执行脚本
+	if proxyUrl := findPACProxy(t, pac, "http://a.com/public/x"); proxyUrl.String() != "http://proxy.example.com:8080" {
+		t.Errorf("/public/x的代理 = %v", proxyUrl)
+	}
+	if proxyUrl := findPACProxy(t, pac, "http://a.com/internal/y"); proxyUrl != directProxy {
+		t.Errorf("/internal/y的代理 = %v, 期望直连", proxyUrl)
+	}
+}
+
+func TestProxyPACCachesDNSResolve(t *testing.T) {
+	lookups := 0
+	lookupIP := func(ctx context.Context, host string) ([]net.IP, error) {
+		lookups++
+		return []net.IP{net.ParseIP("10.1.2.3")}, nil
+	}
+	script := `function FindProxyForURL(url, host) {
+		if (isInNet(host, "10.0.0.0", "255.0.0.0")) { return "DIRECT"; }
+		return "PROXY proxy.example.com:8080";
+	}`
+	pac, err := newProxyPAC(script, lookupIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{"http://intranet.example.com/a", "http://intranet.example.com/b"} {
+		if proxyUrl := findPACProxy(t, pac, target); proxyUrl != directProxy {
+			t.Errorf("%s的代理 = %v, 期望直连", target, proxyUrl)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("解析次数 = %d, 期望1", lookups)
+	}
+}
+
+func TestProxyPACDNSResolveDeadline(t *testing.T) {
+	lookups := 0
+	lookupIP := func(ctx context.Context, host string) ([]net.IP, error) {
+		lookups++
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > pacTimeout {
+			t.Errorf("解析的截止时间 = %v, %v, 期望不超过pacTimeout", deadline, ok)
+		}
+		if host == "slow.example.com" {
+			// 模拟解析超时
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []net.IP{net.ParseIP("10.1.2.3")}, nil
+	}
+	script := `function FindProxyForURL(url, host) {
+		return isResolvable(host) ? "DIRECT" : "PROXY proxy.example.com:8080";
+	}`
+	pac, err := newProxyPAC(script, lookupIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxyUrl := findPACProxy(t, pac, "http://fast.example.com/"); proxyUrl != directProxy {
+		t.Errorf("代理 = %v, 期望直连", proxyUrl)
+	}
+
+	// 解析在本次执行的截止时间返回, 超时的结果不缓存
+	pac.deadline = time.Now().Add(50 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		value, err := pac.vm.Call("dnsResolve", nil, "slow.example.com")
+		if err != nil || !value.IsNull() {
+			t.Errorf("dnsResolve = %v, %v, 期望null", value, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("解析耗时 = %v, 期望在截止时间返回", elapsed)
+	}
+	if lookups != 3 {
+		t.Errorf("解析次数 = %d, 期望3", lookups)
+	}
+}
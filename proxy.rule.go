@@ -0,0 +1,150 @@
+package gogorequest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// 直连, 可以作为代理规则、PAC的结果, 也可以作为请求的代理跳过环境变量代理
+const ProxyDirect = "DIRECT"
+
+var directProxy = &url.URL{Scheme: "direct"}
+
+// 代理规则的匹配方式
+type ProxyMatch int
+
+const (
+	ProxyMatchGlob   ProxyMatch = iota // 主机名通配符, *匹配任意字符, ?匹配单个字符, 如*.example.com
+	ProxyMatchRegexp                   // 主机名正则表达式
+	ProxyMatchCIDR                     // 主机IP所在网段, 如10.0.0.0/8, 主机为域名时解析后匹配
+)
+
+// 代理规则, 按主机选择代理
+type ProxyRule struct {
+	Match   ProxyMatch
+	Pattern string
+	Proxies []string // 代理地址或DIRECT, 多个时按请求轮换
+}
+
+type proxyRule struct {
+	next    uint64 // 原子操作的计数器放在开头, 保证在32位平台上按8字节对齐
+	match   ProxyMatch
+	pattern *regexp.Regexp
+	network *net.IPNet
+	proxies []*url.URL
+}
+
+// 设置代理规则, 未设置代理的请求按顺序使用第一个匹配主机的规则, 都不匹配时使用PAC或环境变量代理
+func (this *mainEngine) SetProxyRules(rules []ProxyRule) error {
+	compiled := make([]*proxyRule, 0, len(rules))
+	for _, rule := range rules {
+		r, err := compileProxyRule(rule)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, r)
+	}
+	this.proxyRules = compiled
+	return nil
+}
+
+func compileProxyRule(rule ProxyRule) (*proxyRule, error) {
+	if len(rule.Proxies) == 0 {
+		return nil, fmt.Errorf("代理规则%s缺少代理", rule.Pattern)
+	}
+	r := proxyRule{match: rule.Match}
+	switch rule.Match {
+	case ProxyMatchGlob:
+		r.pattern = globRegexp(rule.Pattern)
+	case ProxyMatchRegexp:
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("代理规则%s无效: %w", rule.Pattern, err)
+		}
+		r.pattern = pattern
+	case ProxyMatchCIDR:
+		_, network, err := net.ParseCIDR(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("代理规则%s无效: %w", rule.Pattern, err)
+		}
+		r.network = network
+	default:
+		return nil, fmt.Errorf("代理规则%s的匹配方式无效", rule.Pattern)
+	}
+	for _, proxies := range rule.Proxies {
+		proxyUrl, err := parseProxy(proxies)
+		if err != nil {
+			return nil, err
+		}
+		if proxyUrl == nil {
+			return nil, fmt.Errorf("代理规则%s的代理为空", rule.Pattern)
+		}
+		r.proxies = append(r.proxies, proxyUrl)
+	}
+	return &r, nil
+}
+
+// 将通配符转换为不区分大小写的正则表达式, *匹配任意字符, ?匹配单个字符
+func globRegexp(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+	return regexp.MustCompile("(?i)^" + expr + "$")
+}
+
+// 判断主机是否匹配规则, CIDR规则在主机为域名时解析后匹配, 解析的IP缓存到ips
+func (this *proxyRule) matches(ctx context.Context, lookupIP func(context.Context, string) ([]net.IP, error), host string, ips *[]net.IP) bool {
+	if this.network == nil {
+		return this.pattern.MatchString(host)
+	}
+	if *ips == nil {
+		*ips = []net.IP{}
+		if ip := net.ParseIP(host); ip != nil {
+			*ips = append(*ips, ip)
+		} else if resolved, err := lookupIP(ctx, host); err == nil {
+			*ips = resolved
+		}
+	}
+	for _, ip := range *ips {
+		if this.network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 轮换选择代理
+func (this *proxyRule) proxy() *url.URL {
+	if len(this.proxies) == 1 {
+		return this.proxies[0]
+	}
+	n := atomic.AddUint64(&this.next, 1) - 1
+	return this.proxies[n%uint64(len(this.proxies))]
+}
+
+// 为未设置代理的请求按代理规则和PAC选择代理, 都没有结果时返回nil
+func (this *mainEngine) routeProxy(ctx context.Context, targetUrl string) (*url.URL, error) {
+	if len(this.proxyRules) == 0 && this.pac == nil {
+		return nil, nil
+	}
+	target, err := url.Parse(targetUrl)
+	if err != nil {
+		return nil, nil
+	}
+	host := target.Hostname()
+	var ips []net.IP
+	for _, rule := range this.proxyRules {
+		if rule.matches(ctx, this.lookupIP, host, &ips) {
+			return rule.proxy(), nil
+		}
+	}
+	if this.pac != nil {
+		return this.pac.findProxy(target)
+	}
+	return nil, nil
+}
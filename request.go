@@ -1,69 +1,62 @@
 package gogorequest
 
 import (
+	"context"
 	"time"
 )
 
-// 同步引擎请求体
-type syncEngineRequestBody struct {
-	URL         string
-	Headers     map[string]string
-	Method      string
-	Body        interface{}
-	Spider      *SyncEngine
-	Proxy       string
-	Timeout     time.Duration
-	Meta        map[string]interface{}
-	RetryNumber int64
-	startTime   time.Time
+// 请求体, 所有引擎共用
+type Request struct {
+	ID             string // 请求ID, 重试时沿用
+	URL            string
+	Headers        map[string]string
+	Method         string
+	Body           interface{}
+	Proxy          string
+	Timeout        time.Duration
+	Meta           map[string]interface{}
+	RetryNumber    int64
+	FilePath       string            // 文件下载引擎的文件保存路径, 上传引擎的待上传文件路径
+	UploadURL      string            // 上传引擎服务端分配的上传地址, 创建上传任务后记录, 重试时从该地址继续上传
+	UploadMetadata map[string]string // 上传引擎创建上传任务时的Upload-Metadata
+	engine         requestEngine
+	startTime      time.Time
+	queueTime      time.Time // 重试请求重新入队的时间
+	options        *requestOptions
 }
 
-func (this *syncEngineRequestBody) Retry() *SyncEngineResponse {
-	return this.Spider.retryVisit(this.Method, this.URL, this.Headers, this.Body, this.Timeout, this.Proxy, this.Meta, this.RetryNumber, this.startTime)
+// 可以重试请求的引擎
+type requestEngine interface {
+	retry(request *Request) *Response
 }
 
-// 异步引擎请求体
-type asyncEngineRequestBody struct {
-	URL         string
-	Headers     map[string]string
-	Method      string
-	Body        interface{}
-	Spider      *AsyncEngine
-	Proxy       string
-	Timeout     time.Duration
-	Meta        map[string]interface{}
-	RetryNumber int64
-	startTime   time.Time
+// 重试请求, 异步引擎的重试结果从ChanResponses获取, 此时返回nil
+func (this *Request) Retry() *Response {
+	return this.engine.retry(this)
 }
 
-func (this *asyncEngineRequestBody) Retry() {
-	this.Spider.retryVisit(this.Method, this.URL, this.Headers, this.Body, this.Timeout, this.Proxy, this.Meta, this.RetryNumber, this.startTime)
+// 生成重试请求, 重试次数+1, 开始时间沿用首次请求的开始时间
+func (this *Request) next() *Request {
+	request := *this
+	request.RetryNumber = this.RetryNumber + 1
+	request.queueTime = time.Now()
+	return &request
 }
 
-// 文件下载引擎请求体
-type fileEngineRequestBody struct {
-	URL       string
-	Headers   map[string]string
-	Method    string
-	Body      interface{}
-	Spider    *FileEngine
-	Proxy     string
-	Timeout   time.Duration
-	FilePath  string
-	startTime time.Time
+// 请求进入队列的时间, 用于统计排队耗时
+func (this *Request) queueStart() time.Time {
+	if !this.queueTime.IsZero() {
+		return this.queueTime
+	}
+	return this.startTime
 }
 
-// 批量异步请求体[引擎自用]
-type batchAsyncEngineRequestBody struct {
-	URL       string
-	Headers   map[string]string
-	Method    string
-	Body      interface{}
-	Spider    *BatchAsyncEngine
-	Proxy     string
-	Timeout   time.Duration
-	Meta      map[string]interface{}
-	startTime time.Time
+// 请求的ctx, 未通过WithContext设置时为context.Background()
+func (this *Request) context() context.Context {
+	if this.options != nil && this.options.ctx != nil {
+		return this.options.ctx
+	}
+	return context.Background()
 }
 
 // 批量异步请求体[用户设置]
@@ -75,4 +68,5 @@ type BatchAsyncEngineRequestBody struct {
 	Proxy   string
 	Timeout time.Duration
 	Meta    map[string]interface{}
+	Options []RequestOption
 }
@@ -0,0 +1,74 @@
+package gogorequest
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 默认携带请求ID的请求头
+const defaultRequestIDHeader = "X-Request-ID"
+
+// 生成随机的请求ID, 格式为UUID v4
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("生成请求ID失败: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// 指定请求ID, 不指定时自动生成
+func WithRequestID(id string) RequestOption {
+	return func(options *requestOptions) {
+		options.requestID = id
+	}
+}
+
+// 获取请求ID, 调用方已在请求头中设置请求ID时使用请求头的值, 保证响应、日志和错误中的请求ID与实际发送的一致
+// 否则使用WithRequestID指定的ID, 都没有时生成新的ID
+func (this *mainEngine) requestID(headers map[string]string, options *requestOptions) string {
+	if header := this.requestIDHeaderName(); header != "" {
+		for h, hv := range headers {
+			if strings.EqualFold(h, header) && hv != "" {
+				return hv
+			}
+		}
+	}
+	if options != nil && options.requestID != "" {
+		return options.requestID
+	}
+	return newRequestID()
+}
+
+// 设置携带请求ID的请求头, 默认为X-Request-ID, 为空时不发送请求ID
+func (this *mainEngine) SetRequestIDHeader(header string) {
+	this.requestIDHeader = header
+	this.requestIDHeaderOff = header == ""
+}
+
+func (this *mainEngine) requestIDHeaderName() string {
+	if this.requestIDHeaderOff {
+		return ""
+	}
+	if this.requestIDHeader == "" {
+		return defaultRequestIDHeader
+	}
+	return this.requestIDHeader
+}
+
+// 在请求错误和状态码错误中记录请求ID
+func withRequestID(err error, id string) error {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		requestErr.RequestID = id
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		statusErr.RequestID = id
+	}
+	return err
+}
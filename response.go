@@ -1,57 +1,70 @@
 package gogorequest
 
 import (
+	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"time"
 )
 
-// 同步引擎响应体
-type SyncEngineResponse struct {
+// 响应体, 所有引擎共用
+type Response struct {
 	Status      bool
 	Error       error
-	Request     *syncEngineRequestBody
+	Request     *Request
+	RequestID   string // 请求ID, 与请求的ID相同, 重试时沿用
 	Response    *http.Response
 	StatusCode  int
-	Text        string
+	Text        string // 响应内容, 调用DisableText后为空
+	Bytes       []byte
+	Body        io.ReadCloser // 流式响应模式下未读取的响应体, 需由调用方关闭
+	BodyFile    string        // 响应体超过大小限制时落盘的临时文件路径, 需由调用方删除
 	StartTime   time.Time
 	EndTime     time.Time
-	ConsumeTime float64
+	ConsumeTime float64 // 从首次请求开始到结束的耗时(秒), 包含排队和重试的时间
+	Timings     Timings
 }
 
-// 文件下载引擎响应体
-type FileEngineResponse struct {
-	Status      bool
-	Error       error
-	Text        string
-	Request     *fileEngineRequestBody
-	StatusCode  int
-	StartTime   time.Time
-	EndTime     time.Time
-	ConsumeTime float64
+// 按响应的Content-Type将响应内容解码到v, 流式响应模式下读取剩余的响应体, 落盘时读取临时文件
+func (this *Response) Decode(v interface{}) error {
+	if this.Response == nil {
+		return errors.New("没有可解码的响应")
+	}
+	if this.Bytes == nil && this.Body != nil {
+		defer this.Body.Close()
+		text, err := ioutil.ReadAll(this.Body)
+		if err != nil {
+			return err
+		}
+		this.Bytes = text
+	}
+	if this.Bytes == nil && this.BodyFile != "" {
+		text, err := ioutil.ReadFile(this.BodyFile)
+		if err != nil {
+			return err
+		}
+		this.Bytes = text
+	}
+	return decodeBody(this.Response.Header.Get("Content-Type"), this.Bytes, v)
 }
 
+// 同步引擎响应体
+type SyncEngineResponse = Response
+
 // 异步引擎响应体
-type AsyncEngineResponse struct {
-	Status      bool
-	Error       error
-	Request     *asyncEngineRequestBody
-	Response    *http.Response
-	StatusCode  int
-	Text        string
-	StartTime   time.Time
-	EndTime     time.Time
-	ConsumeTime float64
-}
+type AsyncEngineResponse = Response
 
 // 批量异步响应体
-type BatchAsyncEngineResponse struct {
-	Status      bool
-	Error       error
-	Request     *batchAsyncEngineRequestBody
-	Response    *http.Response
-	StatusCode  int
-	Text        string
-	StartTime   time.Time
-	EndTime     time.Time
-	ConsumeTime float64
+type BatchAsyncEngineResponse = Response
+
+// 文件下载引擎响应体
+type FileEngineResponse = Response
+
+// 上传引擎响应体, 在通用响应体的基础上记录上传进度
+type UploadEngineResponse struct {
+	Response
+	UploadURL string // 服务端分配的上传地址, 可用于断点续传
+	Offset    int64  // 服务端已确认接收的字节数
+	Size      int64  // 文件总字节数
 }
@@ -0,0 +1,380 @@
+package gogorequest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+	sigV4StreamPayload   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	sigV4EmptyHash       = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	sigV4ChunkSize       = 64 * 1024
+)
+
+// SigV4请求体签名方式
+type SigV4Payload int
+
+const (
+	SigV4SignedPayload   SigV4Payload = iota // 计算请求体的SHA256, 请求体需可以重复读取
+	SigV4UnsignedPayload                     // 不签名请求体(UNSIGNED-PAYLOAD)
+	SigV4StreamPayload                       // 按aws-chunked编码分块签名, 请求体长度需已知
+)
+
+// 签名时不参与计算的请求头, 可能被代理或Transport修改
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"expect":          true,
+	"connection":      true,
+	"x-amzn-trace-id": true,
+}
+
+// AWS Signature V4签名, 可作为引擎或请求的认证方式, 兼容MinIO等S3协议的对象存储
+type SigV4Signer struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+	service      string
+	payload      SigV4Payload
+}
+
+// 实例化SigV4签名, service为服务名, S3协议的对象存储为s3
+func NewSigV4Signer(accessKey string, secretKey string, region string, service string) *SigV4Signer {
+	return &SigV4Signer{accessKey: accessKey, secretKey: secretKey, region: region, service: service}
+}
+
+// 设置临时凭证的session token
+func (this *SigV4Signer) SetSessionToken(token string) {
+	this.sessionToken = token
+}
+
+// 设置请求体签名方式, 默认为SigV4SignedPayload
+func (this *SigV4Signer) SetPayload(payload SigV4Payload) {
+	this.payload = payload
+}
+
+// 在请求头中添加签名, 请求头中已有X-Amz-Date时使用该时间签名
+func (this *SigV4Signer) Authenticate(req *http.Request) error {
+	now := time.Now().UTC()
+	if date := req.Header.Get("X-Amz-Date"); date != "" {
+		if t, err := time.Parse(sigV4TimeFormat, date); err == nil {
+			now = t
+		}
+	}
+	payloadHash, err := this.payloadHash(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	if this.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", this.sessionToken)
+	}
+	if this.service == "s3" || payloadHash == sigV4UnsignedPayload || payloadHash == sigV4StreamPayload {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if payloadHash == sigV4StreamPayload {
+		this.prepareStream(req)
+	}
+
+	headers := this.signedHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		this.canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders(headers),
+		signedHeaderNames(headers),
+		payloadHash,
+	}, "\n")
+	scope := this.scope(now)
+	signature := this.sign(now, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, this.accessKey, scope, signedHeaderNames(headers), signature))
+
+	if payloadHash == sigV4StreamPayload {
+		req.Body = &awsChunkedReader{body: req.Body, key: this.signingKey(now), date: now.Format(sigV4TimeFormat), scope: scope, signature: signature}
+	}
+	return nil
+}
+
+// 生成预签名URL, 可直接用于任意引擎的GET、PUT请求, expires为有效期, 最长7天
+func (this *SigV4Signer) Presign(method string, targetUrl string, expires time.Duration) (string, error) {
+	u, err := url.Parse(targetUrl)
+	if err != nil {
+		return "", err
+	}
+	return this.presign(method, u, expires, time.Now().UTC())
+}
+
+func (this *SigV4Signer) presign(method string, u *url.URL, expires time.Duration, now time.Time) (string, error) {
+	if expires <= 0 || expires > 7*24*time.Hour {
+		return "", errors.New("预签名URL的有效期需在1秒到7天之间")
+	}
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", this.accessKey+"/"+this.scope(now))
+	query.Set("X-Amz-Date", now.Format(sigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if this.sessionToken != "" {
+		query.Set("X-Amz-Security-Token", this.sessionToken)
+	}
+	payloadHash := sigV4UnsignedPayload
+	if this.service != "s3" {
+		payloadHash = sigV4EmptyHash
+	}
+	canonicalRequest := strings.Join([]string{
+		method,
+		this.canonicalURI(u),
+		canonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		payloadHash,
+	}, "\n")
+	query.Set("X-Amz-Signature", this.sign(now, canonicalRequest))
+	presigned := *u
+	presigned.RawQuery = canonicalQuery(query)
+	return presigned.String(), nil
+}
+
+// 请求体的SHA256
+func (this *SigV4Signer) payloadHash(req *http.Request) (string, error) {
+	switch this.payload {
+	case SigV4UnsignedPayload:
+		return sigV4UnsignedPayload, nil
+	case SigV4StreamPayload:
+		if req.Body == nil || req.Body == http.NoBody {
+			return sigV4EmptyHash, nil
+		}
+		if req.ContentLength < 0 {
+			return "", errors.New("SigV4分块签名需要已知的请求体长度")
+		}
+		return sigV4StreamPayload, nil
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return sigV4EmptyHash, nil
+	}
+	if req.GetBody == nil {
+		return "", errors.New("SigV4签名请求体需要可以重复读取的请求体, 请使用UNSIGNED-PAYLOAD或分块签名")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// 分块签名时设置aws-chunked编码的请求头和编码后的长度
+func (this *SigV4Signer) prepareStream(req *http.Request) {
+	length := req.ContentLength
+	req.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(length, 10))
+	if encoding := req.Header.Get("Content-Encoding"); encoding != "" {
+		req.Header.Set("Content-Encoding", "aws-chunked,"+encoding)
+	} else {
+		req.Header.Set("Content-Encoding", "aws-chunked")
+	}
+	req.ContentLength = awsChunkedLength(length)
+	req.GetBody = nil
+}
+
+// 参与签名的请求头, 键为小写
+func (this *SigV4Signer) signedHeaders(req *http.Request) map[string]string {
+	headers := map[string]string{}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers["host"] = host
+	if req.ContentLength > 0 {
+		headers["content-length"] = strconv.FormatInt(req.ContentLength, 10)
+	}
+	for h, values := range req.Header {
+		name := strings.ToLower(h)
+		if sigV4IgnoredHeaders[name] {
+			continue
+		}
+		trimmed := make([]string, 0, len(values))
+		for _, v := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+	return headers
+}
+
+// 规范化路径, S3只编码一次, 其他服务编码两次
+func (this *SigV4Signer) canonicalURI(u *url.URL) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	path = awsURIEncode(path, false)
+	if this.service != "s3" {
+		path = awsURIEncode(path, false)
+	}
+	return path
+}
+
+func (this *SigV4Signer) scope(now time.Time) string {
+	return strings.Join([]string{now.Format("20060102"), this.region, this.service, "aws4_request"}, "/")
+}
+
+func (this *SigV4Signer) signingKey(now time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+this.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, this.region)
+	key = hmacSHA256(key, this.service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func (this *SigV4Signer) sign(now time.Time, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		now.Format(sigV4TimeFormat),
+		this.scope(now),
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(this.signingKey(now), stringToSign))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func canonicalHeaders(headers map[string]string) string {
+	names := sortedHeaderNames(headers)
+	var builder strings.Builder
+	for _, name := range names {
+		builder.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	return builder.String()
+}
+
+func signedHeaderNames(headers map[string]string) string {
+	return strings.Join(sortedHeaderNames(headers), ";")
+}
+
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 按AWS规则编码, 只保留字母、数字和-._~
+func awsURIEncode(s string, encodeSlash bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~' || (c == '/' && !encodeSlash) {
+			builder.WriteByte(c)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+	return builder.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// aws-chunked编码后的长度, 包含结尾的空分块
+func awsChunkedLength(length int64) int64 {
+	chunkLength := func(size int64) int64 {
+		return int64(len(strconv.FormatInt(size, 16))) + int64(len(";chunk-signature=")) + 64 + 2 + size + 2
+	}
+	total := (length / sigV4ChunkSize) * chunkLength(sigV4ChunkSize)
+	if rem := length % sigV4ChunkSize; rem > 0 {
+		total += chunkLength(rem)
+	}
+	return total + chunkLength(0)
+}
+
+// aws-chunked编码的请求体, 每个分块的签名以上一个分块的签名为种子
+type awsChunkedReader struct {
+	body      io.ReadCloser
+	key       []byte
+	date      string
+	scope     string
+	signature string
+	buf       bytes.Buffer
+	done      bool
+}
+
+func (this *awsChunkedReader) Read(p []byte) (int, error) {
+	for this.buf.Len() == 0 {
+		if this.done {
+			return 0, io.EOF
+		}
+		chunk := make([]byte, sigV4ChunkSize)
+		n, err := io.ReadFull(this.body, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		this.writeChunk(chunk[:n])
+		if n == 0 {
+			this.done = true
+		}
+	}
+	return this.buf.Read(p)
+}
+
+func (this *awsChunkedReader) writeChunk(chunk []byte) {
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD",
+		this.date,
+		this.scope,
+		this.signature,
+		sigV4EmptyHash,
+		sha256Hex(chunk),
+	}, "\n")
+	this.signature = hex.EncodeToString(hmacSHA256(this.key, stringToSign))
+	fmt.Fprintf(&this.buf, "%x;chunk-signature=%s\r\n", len(chunk), this.signature)
+	this.buf.Write(chunk)
+	this.buf.WriteString("\r\n")
+}
+
+func (this *awsChunkedReader) Close() error {
+	return this.body.Close()
+}
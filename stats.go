@@ -0,0 +1,238 @@
+package gogorequest
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 延迟统计的采样窗口, 只保留最近的请求耗时
+const latencyWindow = 1024
+
+// 按域名统计的最大域名数, 超过后新出现的域名合并统计到otherHost
+const (
+	maxTrackedHosts = 256
+	otherHost       = "other"
+)
+
+// 请求生命周期观察者, 由引擎在执行请求时通知
+type observer interface {
+	requestQueued(request *Request)
+	requestDequeued(request *Request)
+	requestStarted(request *Request)
+	requestFinished(request *Request, response *Response, bytesIn int64, bytesOut int64)
+}
+
+func (this *mainEngine) notifyQueued(request *Request) {
+	for _, o := range this.observers {
+		o.requestQueued(request)
+	}
+}
+
+func (this *mainEngine) notifyDequeued(request *Request) {
+	for _, o := range this.observers {
+		o.requestDequeued(request)
+	}
+}
+
+func (this *mainEngine) notifyStarted(request *Request) {
+	for _, o := range this.observers {
+		o.requestStarted(request)
+	}
+}
+
+func (this *mainEngine) notifyFinished(request *Request, response *Response, bytesIn int64, bytesOut int64) {
+	for _, o := range this.observers {
+		o.requestFinished(request, response, bytesIn, bytesOut)
+	}
+}
+
+// 引擎统计信息
+type EngineStats struct {
+	InFlight  int64 // 正在执行的请求数
+	Queued    int64 // 等待执行的请求数
+	Completed int64 // 已完成的请求数, 包含失败的请求
+	Failed    int64 // 失败的请求数
+	Retried   int64 // 重试的请求数
+	BytesIn   int64 // 接收的响应体字节数
+	BytesOut  int64 // 发送的请求体字节数
+	Latency   LatencyStats
+	Hosts     map[string]HostStats // 按域名统计, 最多256个域名, 之后新出现的域名合并到"other"
+}
+
+// 单个域名的统计信息
+type HostStats struct {
+	InFlight  int64
+	Queued    int64
+	Completed int64
+	Failed    int64
+	Retried   int64
+	BytesIn   int64
+	BytesOut  int64
+	Latency   LatencyStats
+}
+
+// 请求耗时分位数, 基于最近的请求计算
+type LatencyStats struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+}
+
+// 获取引擎的统计信息
+func (this *mainEngine) Stats() EngineStats {
+	return this.stats.snapshot()
+}
+
+// 计数器和耗时采样
+type statsCounter struct {
+	inFlight  int64
+	queued    int64
+	completed int64
+	failed    int64
+	retried   int64
+	bytesIn   int64
+	bytesOut  int64
+	latencies []time.Duration
+	next      int
+}
+
+func (this *statsCounter) addLatency(latency time.Duration) {
+	if len(this.latencies) < latencyWindow {
+		this.latencies = append(this.latencies, latency)
+		return
+	}
+	this.latencies[this.next] = latency
+	this.next = (this.next + 1) % latencyWindow
+}
+
+func (this *statsCounter) latency() LatencyStats {
+	if len(this.latencies) == 0 {
+		return LatencyStats{}
+	}
+	sorted := make([]time.Duration, len(this.latencies))
+	copy(sorted, this.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencyStats{
+		P50: percentile(sorted, 50),
+		P90: percentile(sorted, 90),
+		P99: percentile(sorted, 99),
+	}
+}
+
+// 最近邻排名法计算分位数, sorted需已升序排列
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// 引擎统计, 作为观察者记录请求的执行情况
+type engineStats struct {
+	lock  sync.Mutex
+	total statsCounter
+	hosts map[string]*statsCounter
+}
+
+func newEngineStats() *engineStats {
+	return &engineStats{hosts: map[string]*statsCounter{}}
+}
+
+// 获取总计数器和域名计数器, 调用方需持有锁
+// 域名数达到上限后新出现的域名使用otherHost的计数器, 已统计的域名不受影响
+func (this *engineStats) counters(request *Request) [2]*statsCounter {
+	host := requestHost(request.URL)
+	hostCounter, ok := this.hosts[host]
+	if !ok && len(this.hosts) >= maxTrackedHosts {
+		host = otherHost
+		hostCounter, ok = this.hosts[host]
+	}
+	if !ok {
+		hostCounter = &statsCounter{}
+		this.hosts[host] = hostCounter
+	}
+	return [2]*statsCounter{&this.total, hostCounter}
+}
+
+func (this *engineStats) requestQueued(request *Request) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for _, counter := range this.counters(request) {
+		counter.queued += 1
+	}
+}
+
+func (this *engineStats) requestDequeued(request *Request) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for _, counter := range this.counters(request) {
+		counter.queued -= 1
+	}
+}
+
+func (this *engineStats) requestStarted(request *Request) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for _, counter := range this.counters(request) {
+		counter.inFlight += 1
+		if request.RetryNumber > 0 {
+			counter.retried += 1
+		}
+	}
+}
+
+func (this *engineStats) requestFinished(request *Request, response *Response, bytesIn int64, bytesOut int64) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for _, counter := range this.counters(request) {
+		counter.inFlight -= 1
+		counter.completed += 1
+		if !response.Status {
+			counter.failed += 1
+		}
+		counter.bytesIn += bytesIn
+		counter.bytesOut += bytesOut
+		counter.addLatency(response.Timings.Total)
+	}
+}
+
+func (this *engineStats) snapshot() EngineStats {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	stats := EngineStats{
+		InFlight:  this.total.inFlight,
+		Queued:    this.total.queued,
+		Completed: this.total.completed,
+		Failed:    this.total.failed,
+		Retried:   this.total.retried,
+		BytesIn:   this.total.bytesIn,
+		BytesOut:  this.total.bytesOut,
+		Latency:   this.total.latency(),
+		Hosts:     map[string]HostStats{},
+	}
+	for host, counter := range this.hosts {
+		stats.Hosts[host] = HostStats{
+			InFlight:  counter.inFlight,
+			Queued:    counter.queued,
+			Completed: counter.completed,
+			Failed:    counter.failed,
+			Retried:   counter.retried,
+			BytesIn:   counter.bytesIn,
+			BytesOut:  counter.bytesOut,
+			Latency:   counter.latency(),
+		}
+	}
+	return stats
+}
+
+// 获取请求的域名, 无法解析时返回空字符串
+func requestHost(targetUrl string) string {
+	u, err := url.Parse(targetUrl)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
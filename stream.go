@@ -0,0 +1,44 @@
+package gogorequest
+
+import (
+	"io"
+	"sync"
+)
+
+// 流式响应体, 读取到结尾或关闭时回调引擎记录耗时, 超过大小限制时返回BodyTooLargeError
+type streamBody struct {
+	body     io.ReadCloser
+	read     int64
+	limit    int64 // 允许读取的最大字节数, 小于等于0表示不限制
+	once     sync.Once
+	onFinish func(read int64)
+}
+
+func (this *streamBody) Read(p []byte) (int, error) {
+	n, err := this.body.Read(p)
+	this.read += int64(n)
+	if this.limit > 0 && this.read > this.limit {
+		n -= int(this.read - this.limit)
+		this.read = this.limit
+		return n, &BodyTooLargeError{Limit: this.limit}
+	}
+	if err == io.EOF {
+		this.finish()
+		return n, err
+	}
+	return n, bodyReadError(err)
+}
+
+func (this *streamBody) Close() error {
+	err := this.body.Close()
+	this.finish()
+	return err
+}
+
+func (this *streamBody) finish() {
+	this.once.Do(func() {
+		if this.onFinish != nil {
+			this.onFinish(this.read)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package gogorequest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 不限制超时时间
+const NoTimeout time.Duration = -1
+
+// 响应体读取空闲超时
+var errBodyIdleTimeout = errors.New("读取响应体空闲超时")
+
+// 各阶段超时配置
+type Timeouts struct {
+	Dial           time.Duration // 建立TCP连接, 为0时不限制
+	TLSHandshake   time.Duration // TLS握手, 为0时保持transport原有配置
+	ResponseHeader time.Duration // 请求发送完毕后等待响应头, 为0时保持transport原有配置
+	BodyIdle       time.Duration // 读取响应体时两次收到数据的最长间隔, 为0时不限制
+	Total          time.Duration // 整个请求(含读取响应体), 请求单独设置了超时时间时以请求为准, 为0时不限制
+}
+
+// 设置各阶段超时, 对当前transport生效, 之后通过SetTransport设置的transport也会应用该配置
+func (this *mainEngine) SetTimeouts(timeouts Timeouts) {
+	this.timeouts = timeouts
+	this.applyTimeouts(this.transport)
+	this.resetCachedTransports()
+}
+
+// 将超时配置应用到transport, 自定义了DialContext的transport需自行控制连接超时
+func (this *mainEngine) applyTimeouts(transport *http.Transport) {
+	if transport.DialContext == nil {
+		transport.DialContext = this.dialContext
+	}
+	if this.timeouts.TLSHandshake > 0 {
+		transport.TLSHandshakeTimeout = this.timeouts.TLSHandshake
+	}
+	if this.timeouts.ResponseHeader > 0 {
+		transport.ResponseHeaderTimeout = this.timeouts.ResponseHeader
+	}
+}
+
+// 建立连接, 应用连接超时、自定义连接方法、域名解析和出口IP配置
+func (this *mainEngine) dialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: this.timeouts.Dial}
+	if this.dial != nil {
+		if this.timeouts.Dial > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, this.timeouts.Dial)
+			defer cancel()
+		}
+		return this.dial(ctx, network, addr)
+	}
+	if this.dns != nil || this.local != nil {
+		return this.dialResolved(ctx, &dialer, network, addr)
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// 请求的总超时时间, 请求未设置时使用引擎配置
+func (this *mainEngine) totalTimeout(timeout time.Duration) time.Duration {
+	if timeout != 0 {
+		return normalizeTimeout(timeout)
+	}
+	return this.timeouts.Total
+}
+
+// 兼容整数秒的写法: 小于1毫秒的值视为秒数, 例如10表示10秒; 小于0表示不限制
+func normalizeTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return 0
+	}
+	if timeout < time.Millisecond {
+		return timeout * time.Second
+	}
+	return timeout
+}
+
+// 带空闲超时的响应体, 超过idle时间没有收到数据时取消请求
+type idleTimeoutBody struct {
+	body     io.ReadCloser
+	idle     time.Duration
+	timer    *time.Timer
+	cancel   context.CancelFunc
+	lock     sync.Mutex
+	timedOut bool
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, idle time.Duration, cancel context.CancelFunc) *idleTimeoutBody {
+	idleBody := &idleTimeoutBody{body: body, idle: idle, cancel: cancel}
+	idleBody.timer = time.AfterFunc(idle, func() {
+		idleBody.lock.Lock()
+		idleBody.timedOut = true
+		idleBody.lock.Unlock()
+		cancel()
+	})
+	return idleBody
+}
+
+func (this *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := this.body.Read(p)
+	this.lock.Lock()
+	timedOut := this.timedOut
+	this.lock.Unlock()
+	if timedOut {
+		return n, &RequestError{Kind: ErrTimeout, Err: errBodyIdleTimeout}
+	}
+	if n > 0 {
+		this.timer.Reset(this.idle)
+	}
+	return n, err
+}
+
+func (this *idleTimeoutBody) Close() error {
+	this.timer.Stop()
+	err := this.body.Close()
+	this.cancel()
+	return err
+}
+
+// 读取响应体出错时的错误类别, 超时归为ErrTimeout, 其他归为ErrBodyRead
+func bodyReadError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errorKind(err) == ErrTimeout {
+		return wrapError(ErrTimeout, err)
+	}
+	return wrapError(ErrBodyRead, err)
+}
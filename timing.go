@@ -0,0 +1,118 @@
+package gogorequest
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// 请求各阶段耗时
+type Timings struct {
+	Queue        time.Duration // 排队等待时间, 异步引擎中为等待并发名额的时间
+	DNS          time.Duration // 域名解析
+	Connect      time.Duration // TCP连接
+	TLSHandshake time.Duration // TLS握手
+	FirstByte    time.Duration // 从开始发送请求到收到响应首字节
+	Transfer     time.Duration // 从收到响应首字节到响应体读取完毕
+	Total        time.Duration // 从开始发送请求到响应体读取完毕, 不含排队时间
+	ConnReused   bool          // 是否复用了已有连接
+}
+
+// 通过httptrace记录请求各阶段的时间点
+type requestTrace struct {
+	bytesOut     int64 // 发送的请求体字节数, 原子操作的计数器放在开头, 保证在32位平台上按8字节对齐
+	lock         sync.Mutex
+	queueStart   time.Time
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	connReused   bool
+	span         Span // 链路追踪span, 未设置Tracer时为nil
+}
+
+// 开始记录, queueStart为请求进入队列的时间
+func newRequestTrace(queueStart time.Time) *requestTrace {
+	return &requestTrace{queueStart: queueStart, start: time.Now()}
+}
+
+// 将httptrace挂载到ctx上
+func (this *requestTrace) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			this.mark(&this.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			this.mark(&this.dnsDone)
+		},
+		ConnectStart: func(network, addr string) {
+			this.markOnce(&this.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				this.mark(&this.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() {
+			this.mark(&this.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			this.mark(&this.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			this.lock.Lock()
+			this.connReused = info.Reused
+			this.lock.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			this.mark(&this.firstByte)
+		},
+	})
+}
+
+func (this *requestTrace) mark(t *time.Time) {
+	this.lock.Lock()
+	*t = time.Now()
+	this.lock.Unlock()
+}
+
+// 同时发起多个连接时只记录第一次开始连接的时间
+func (this *requestTrace) markOnce(t *time.Time) {
+	this.lock.Lock()
+	if t.IsZero() {
+		*t = time.Now()
+	}
+	this.lock.Unlock()
+}
+
+// 生成耗时统计, done为响应体读取完毕的时间
+func (this *requestTrace) timings(done time.Time) Timings {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	var timings Timings
+	if !this.queueStart.IsZero() && this.start.After(this.queueStart) {
+		timings.Queue = this.start.Sub(this.queueStart)
+	}
+	timings.DNS = between(this.dnsStart, this.dnsDone)
+	timings.Connect = between(this.connectStart, this.connectDone)
+	timings.TLSHandshake = between(this.tlsStart, this.tlsDone)
+	timings.FirstByte = between(this.start, this.firstByte)
+	timings.Transfer = between(this.firstByte, done)
+	timings.Total = between(this.start, done)
+	timings.ConnReused = this.connReused
+	return timings
+}
+
+// 两个时间点之间的耗时, 任一时间点未记录时为0
+func between(start time.Time, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
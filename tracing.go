@@ -0,0 +1,158 @@
+package gogorequest
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 链路追踪接口, 可以包装OpenTelemetry的Tracer实现, 引擎为每次请求(包括重试)创建一个客户端span
+type Tracer interface {
+	// 以ctx中的span为父span创建新的span, 返回携带新span的ctx
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// 链路追踪span
+type Span interface {
+	SpanContext() SpanContext
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// W3C Trace Context中的span标识
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string // tracestate请求头的原始内容
+}
+
+// TraceID和SpanID均不为全0时有效
+func (this SpanContext) IsValid() bool {
+	return this.TraceID != [16]byte{} && this.SpanID != [8]byte{}
+}
+
+// 生成traceparent请求头
+func (this SpanContext) Traceparent() string {
+	flags := "00"
+	if this.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(this.TraceID[:]), hex.EncodeToString(this.SpanID[:]), flags)
+}
+
+// 解析traceparent请求头, 用于服务端将上游的链路信息传递给gogorequest发出的请求
+func ParseTraceparent(traceparent string, tracestate string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("traceparent格式错误: %q", traceparent)
+	}
+	traceID, traceErr := hex.DecodeString(parts[1])
+	spanID, spanErr := hex.DecodeString(parts[2])
+	flags, flagsErr := hex.DecodeString(parts[3])
+	if traceErr != nil || spanErr != nil || flagsErr != nil || len(traceID) != 16 || len(spanID) != 8 || len(flags) != 1 {
+		return sc, fmt.Errorf("traceparent格式错误: %q", traceparent)
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&1 == 1
+	sc.TraceState = tracestate
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("traceparent格式错误: %q", traceparent)
+	}
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// 将span标识保存到ctx中, 未设置Tracer时引擎直接将其作为traceparent传递
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// 从ctx中获取span标识
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// 为引擎设置链路追踪
+func (this *mainEngine) SetTracer(tracer Tracer) {
+	this.tracer = tracer
+}
+
+// 开始请求的span并注入traceparent、tracestate请求头, 未设置Tracer时只传递ctx中的span标识
+func (this *mainEngine) startSpan(ctx context.Context, request *Request, req *http.Request) (context.Context, Span) {
+	var span Span
+	if this.tracer != nil {
+		ctx, span = this.tracer.Start(ctx, "HTTP "+req.Method)
+		span.SetAttribute("http.request.method", req.Method)
+		span.SetAttribute("url.full", redactedURL(req.URL))
+		span.SetAttribute("server.address", req.URL.Hostname())
+		if request.RetryNumber > 0 {
+			span.SetAttribute("http.request.resend_count", request.RetryNumber)
+		}
+	}
+	sc, ok := SpanContextFromContext(ctx)
+	if span != nil && span.SpanContext().IsValid() {
+		sc, ok = span.SpanContext(), true
+	}
+	// 调用方已设置traceparent时不覆盖
+	if ok && req.Header.Get("traceparent") == "" {
+		req.Header.Set("traceparent", sc.Traceparent())
+		if sc.TraceState != "" {
+			req.Header.Set("tracestate", sc.TraceState)
+		}
+	}
+	return ctx, span
+}
+
+// 结束请求的span, 记录状态码和错误
+func endSpan(span Span, response *Response) {
+	if span == nil {
+		return
+	}
+	if response.StatusCode > 0 {
+		span.SetAttribute("http.response.status_code", response.StatusCode)
+	}
+	if response.Error != nil {
+		span.SetAttribute("error.type", errorType(response.Error))
+		span.RecordError(response.Error)
+	}
+	span.End()
+}
+
+// 错误类型, 状态码错误为状态码, 取消请求为canceled, 其他为错误类别
+func errorType(err error) string {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return fmt.Sprint(statusErr.StatusCode)
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		if requestErr.Kind != nil {
+			return requestErr.Kind.Error()
+		}
+		return fmt.Sprintf("%T", requestErr.Err)
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// 去除URL中的用户密码
+func redactedURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	copied := *u
+	copied.User = nil
+	return copied.String()
+}
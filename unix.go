@@ -0,0 +1,55 @@
+package gogorequest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Unix socket请求的URL前缀, 如unix:///var/run/docker.sock:/containers/json
+const unixScheme = "unix://"
+
+// 所有请求通过指定的Unix socket连接, 请求URL的主机只用于Host请求头, 如http://docker/containers/json
+func (this *mainEngine) SetUnixSocket(path string) {
+	this.unixSocket = path
+}
+
+// 使用自定义的方法建立连接, 连接超时配置通过ctx生效, 域名解析和出口IP配置不再生效
+func (this *mainEngine) SetDialContext(dial func(ctx context.Context, network string, addr string) (net.Conn, error)) {
+	this.dial = dial
+}
+
+// 将unix://socket路径:请求路径拆分为socket路径和HTTP请求的URL, 不是Unix socket请求时ok为false
+func unixRequestURL(targetUrl string) (socket string, httpUrl string, ok bool, err error) {
+	if !strings.HasPrefix(targetUrl, unixScheme) {
+		return "", "", false, nil
+	}
+	rest := strings.TrimPrefix(targetUrl, unixScheme)
+	socket, path := rest, "/"
+	if i := strings.Index(rest, ":"); i >= 0 {
+		socket, path = rest[:i], rest[i+1:]
+	}
+	if socket == "" {
+		return "", "", true, fmt.Errorf("Unix socket请求缺少socket路径: %s", targetUrl)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return socket, "http://localhost" + path, true, nil
+}
+
+// 获取连接指定Unix socket的transport, 只有unix://请求和设置了SetUnixSocket的引擎使用
+// 每个socket使用独立的transport和连接池, 共用的transport不会连接到Unix socket
+func (this *mainEngine) unixTransport(socket string) (*http.Transport, error) {
+	return this.cachedTransport("unix:"+socket, func() (*http.Transport, error) {
+		transport := this.transport.Clone()
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			dialer := net.Dialer{Timeout: this.timeouts.Dial}
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		return transport, nil
+	})
+}
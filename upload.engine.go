@@ -0,0 +1,358 @@
+package gogorequest
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tusVersion = "1.0.0"
+
+// 断点续传上传引擎(tus 1.0协议)
+type UploadEngine struct {
+	mainEngine // 继承主引擎
+	chunkSize  int64
+	maxResume  int64
+}
+
+// 设置每次PATCH上传的分片大小
+func (this *UploadEngine) SetChunkSize(size int64) {
+	if size <= 0 {
+		panic("上传分片大小必须大于0")
+	}
+	this.chunkSize = size
+}
+
+// 设置单次上传中断后自动续传的最大次数
+func (this *UploadEngine) SetMaxResume(num int64) {
+	this.maxResume = num
+}
+
+// 创建上传任务并上传文件, metadata会编码为Upload-Metadata请求头
+func (this *UploadEngine) Visit(targetUrl string, headers map[string]string, timeout time.Duration, proxies string, filepath string, metadata map[string]string, opts ...RequestOption) *UploadEngineResponse {
+	options := newRequestOptions(opts)
+	request := Request{
+		ID:             this.requestID(headers, options),
+		URL:            targetUrl,
+		Method:         "PATCH",
+		Headers:        headers,
+		Proxy:          proxies,
+		Timeout:        timeout,
+		FilePath:       filepath,
+		UploadMetadata: metadata,
+		engine:         this,
+		startTime:      time.Now(),
+		options:        options,
+	}
+	return this.get(&request)
+}
+
+// 根据已有的上传地址继续上传文件
+func (this *UploadEngine) Resume(uploadUrl string, headers map[string]string, timeout time.Duration, proxies string, filepath string, opts ...RequestOption) *UploadEngineResponse {
+	options := newRequestOptions(opts)
+	request := Request{
+		ID:        this.requestID(headers, options),
+		URL:       uploadUrl,
+		Method:    "PATCH",
+		Headers:   headers,
+		Proxy:     proxies,
+		Timeout:   timeout,
+		FilePath:  filepath,
+		UploadURL: uploadUrl,
+		engine:    this,
+		startTime: time.Now(),
+		options:   options,
+	}
+	return this.get(&request)
+}
+
+// 从服务端记录的偏移量继续上传, 已创建上传任务时不再重新创建
+func (this *UploadEngine) retry(request *Request) *Response {
+	return &this.get(request.next()).Response
+}
+
+// 整个上传过程作为一个请求统计
+func (this *UploadEngine) get(request *Request) *UploadEngineResponse {
+	this.notifyStarted(request)
+	response, uploaded := this.upload(request)
+	this.notifyFinished(request, &response.Response, 0, uploaded)
+	return response
+}
+
+// 上传文件, 返回响应和本次上传的字节数
+func (this *UploadEngine) upload(request *Request) (*UploadEngineResponse, int64) {
+	var uploaded int64 = 0
+	progress := uploadProgress{start: time.Now()}
+	file, openFileErr := os.Open(request.FilePath)
+	if openFileErr != nil {
+		return this.onUploadError(nil, openFileErr, request, &progress), uploaded
+	}
+	defer file.Close()
+	stat, statErr := file.Stat()
+	if statErr != nil {
+		return this.onUploadError(nil, statErr, request, &progress), uploaded
+	}
+	progress.size = stat.Size()
+
+	// 没有上传地址时先创建上传任务, 新任务的偏移量为0
+	progress.offset = -1
+	var lastRes *http.Response
+	if request.UploadURL == "" {
+		res, createErr := this.create(request, &progress)
+		if createErr != nil {
+			progress.offset = 0
+			return this.onUploadError(res, createErr, request, &progress), uploaded
+		}
+		lastRes = res
+		progress.offset = 0
+	}
+
+	counter := &writeCounter{Size: uint64(progress.size), reporter: this.progressReporter("Uploading")}
+	var resumeNumber int64 = 0
+	for {
+		// 偏移量未知时通过HEAD向服务端查询
+		if progress.offset < 0 {
+			res, serverOffset, headErr := this.head(request, &progress)
+			if headErr != nil {
+				progress.offset = 0
+				return this.onUploadError(res, headErr, request, &progress), uploaded
+			}
+			lastRes = res
+			progress.offset = serverOffset
+		}
+		if progress.offset >= progress.size {
+			break
+		}
+
+		counter.Total = uint64(progress.offset)
+		res, newOffset, patchErr := this.patch(request, file, &progress, counter)
+		if res != nil {
+			lastRes = res
+		}
+		if patchErr != nil {
+			// 上传中断, 重新查询偏移量后续传
+			resumeNumber += 1
+			if resumeNumber > this.maxResume {
+				return this.onUploadError(res, patchErr, request, &progress), uploaded
+			}
+			progress.offset = -1
+			continue
+		}
+		uploaded += newOffset - progress.offset
+		progress.offset = newOffset
+	}
+	return this.onUploadResponse(lastRes, request, &progress), uploaded
+}
+
+// 上传进度和各次tus请求累计的耗时
+type uploadProgress struct {
+	start   time.Time
+	offset  int64
+	size    int64
+	timings Timings
+}
+
+// 累加一次tus请求的各阶段耗时, 是否复用连接以最后一次请求为准
+func (this *uploadProgress) addTimings(timings Timings) {
+	this.timings.DNS += timings.DNS
+	this.timings.Connect += timings.Connect
+	this.timings.TLSHandshake += timings.TLSHandshake
+	this.timings.FirstByte += timings.FirstByte
+	this.timings.Transfer += timings.Transfer
+	this.timings.ConnReused = timings.ConnReused
+}
+
+// 通过引擎的发送入口发送tus协议请求, headers会与用户请求头合并, 请求沿用上传任务的请求ID和请求配置
+// 读取并关闭响应体, 状态码校验失败时返回HTTPStatusError
+func (this *UploadEngine) sendTus(request *Request, method string, targetUrl string, headers map[string]string, body interface{}, progress *uploadProgress) (*http.Response, []byte, error) {
+	tusHeaders := map[string]string{}
+	for h, hv := range request.Headers {
+		tusHeaders[h] = hv
+	}
+	for h, hv := range headers {
+		tusHeaders[h] = hv
+	}
+	tusHeaders["Tus-Resumable"] = tusVersion
+	trace := newRequestTrace(time.Time{})
+	res, sendErr := this.send(&Request{
+		ID:          request.ID,
+		URL:         targetUrl,
+		Method:      method,
+		Headers:     tusHeaders,
+		Body:        body,
+		Proxy:       request.Proxy,
+		Timeout:     request.Timeout,
+		RetryNumber: request.RetryNumber,
+		startTime:   time.Now(),
+		options:     request.options,
+	}, trace)
+	var data []byte
+	if sendErr == nil {
+		if statusErr := this.checkStatus(res, request.options); statusErr != nil {
+			statusErr.Body = this.readErrorBody(res)
+			sendErr = statusErr
+		} else {
+			data = this.readErrorBody(res)
+		}
+		res.Body.Close()
+	}
+
+	// 每次tus请求单独结束span, 耗时累加到上传任务
+	response := Response{Error: sendErr}
+	if res != nil {
+		response.StatusCode = res.StatusCode
+	}
+	endSpan(trace.span, &response)
+	progress.addTimings(trace.timings(time.Now()))
+	return res, data, sendErr
+}
+
+// POST创建上传任务, 记录服务端返回的上传地址
+func (this *UploadEngine) create(request *Request, progress *uploadProgress) (*http.Response, error) {
+	headers := map[string]string{"Upload-Length": strconv.FormatInt(progress.size, 10)}
+	if len(request.UploadMetadata) > 0 {
+		headers["Upload-Metadata"] = encodeTusMetadata(request.UploadMetadata)
+	}
+	res, body, sendErr := this.sendTus(request, "POST", request.URL, headers, nil, progress)
+	if sendErr != nil {
+		return res, sendErr
+	}
+	if res.StatusCode != http.StatusCreated {
+		return res, &HTTPStatusError{StatusCode: res.StatusCode, Status: res.Status, Body: body}
+	}
+	location, parseErr := res.Request.URL.Parse(res.Header.Get("Location"))
+	if parseErr != nil || res.Header.Get("Location") == "" {
+		return res, errors.New("创建上传任务失败, 服务端未返回有效的Location")
+	}
+	request.UploadURL = location.String()
+	return res, nil
+}
+
+// HEAD查询服务端已接收的偏移量
+func (this *UploadEngine) head(request *Request, progress *uploadProgress) (*http.Response, int64, error) {
+	res, _, sendErr := this.sendTus(request, "HEAD", request.UploadURL, nil, nil, progress)
+	if sendErr != nil {
+		return res, 0, sendErr
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return res, 0, &HTTPStatusError{StatusCode: res.StatusCode, Status: res.Status}
+	}
+	offset, parseErr := strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
+	if parseErr != nil {
+		return res, 0, errors.New("查询上传偏移量失败, 服务端未返回有效的Upload-Offset")
+	}
+	return res, offset, nil
+}
+
+// PATCH上传从当前偏移量开始的一个分片, 返回服务端确认的新偏移量
+func (this *UploadEngine) patch(request *Request, file *os.File, progress *uploadProgress, counter *writeCounter) (*http.Response, int64, error) {
+	offset, size := progress.offset, progress.size
+	length := this.chunkSize
+	if offset+length > size {
+		length = size - offset
+	}
+	chunk := &chunkReader{reader: io.TeeReader(io.NewSectionReader(file, offset, length), counter), remain: length}
+	headers := map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.FormatInt(offset, 10),
+	}
+	res, body, sendErr := this.sendTus(request, "PATCH", request.UploadURL, headers, chunk, progress)
+	if sendErr != nil {
+		return res, 0, sendErr
+	}
+	if res.StatusCode != http.StatusNoContent {
+		return res, 0, &HTTPStatusError{StatusCode: res.StatusCode, Status: res.Status, Body: body}
+	}
+	newOffset, parseErr := strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
+	if parseErr != nil {
+		return res, 0, errors.New("上传分片失败, 服务端未返回有效的Upload-Offset")
+	}
+	// 偏移量没有前进或超过文件大小时视为上传失败, 避免一直重复上传同一分片
+	if newOffset <= offset || newOffset > size {
+		return res, 0, fmt.Errorf("上传分片失败, 服务端返回的Upload-Offset无效: %d, 上传前为%d, 文件大小为%d", newOffset, offset, size)
+	}
+	return res, newOffset, nil
+}
+
+// 上传分片, 通过Len提供剩余长度以设置Content-Length
+type chunkReader struct {
+	reader io.Reader
+	remain int64
+}
+
+func (this *chunkReader) Read(p []byte) (int, error) {
+	n, err := this.reader.Read(p)
+	this.remain -= int64(n)
+	return n, err
+}
+
+func (this *chunkReader) Len() int {
+	return int(this.remain)
+}
+
+// 生成上传引擎响应体, 上传由多次请求组成, 各阶段耗时为所有请求之和, Total为整个上传过程的耗时
+func (this *UploadEngine) newUploadResponse(res *http.Response, request *Request, progress *uploadProgress) *UploadEngineResponse {
+	var response UploadEngineResponse
+	endTime := time.Now()
+	// 没有收到响应时状态码为0
+	if res != nil {
+		response.StatusCode = res.StatusCode
+	}
+	response.Response.Response = res
+	response.Request = request
+	response.RequestID = request.ID
+	response.UploadURL = request.UploadURL
+	response.Offset = progress.offset
+	response.Size = progress.size
+	response.StartTime = request.startTime
+	response.EndTime = endTime
+	response.ConsumeTime = endTime.Sub(request.startTime).Seconds()
+	response.Timings = progress.timings
+	response.Timings.Total = endTime.Sub(progress.start)
+	return &response
+}
+
+func (this *UploadEngine) onUploadError(res *http.Response, err error, request *Request, progress *uploadProgress) *UploadEngineResponse {
+	response := this.newUploadResponse(res, request, progress)
+	response.Status = false
+	response.Error = withRequestID(err, request.ID)
+	return response
+}
+
+func (this *UploadEngine) onUploadResponse(res *http.Response, request *Request, progress *uploadProgress) *UploadEngineResponse {
+	response := this.newUploadResponse(res, request, progress)
+	response.Status = true
+	response.Error = nil
+	return response
+}
+
+// 按tus协议编码Upload-Metadata: key base64(value), 多个键值对用逗号分隔
+func encodeTusMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(metadata[k])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// 实例化上传引擎
+func NewUploadEngine() *UploadEngine {
+	s := UploadEngine{}
+	s.chunkSize = 4 * 1024 * 1024
+	s.maxResume = 3
+	s.initTransport()
+	s.initStats()
+	return &s
+}
@@ -0,0 +1,379 @@
+package gogorequest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试用的tus服务端, 只实现上传引擎用到的POST、HEAD和PATCH
+type tusServer struct {
+	lock sync.Mutex
+	// 各上传地址已接收的内容
+	uploads map[string][]byte
+	lengths map[string]int64
+	// 请求记录, 如"POST /files"、"PATCH /files/1 0"
+	calls    []string
+	headers  []http.Header
+	metadata string
+	// 第n次PATCH(从1开始)接收一半数据后断开连接
+	interruptPatch int
+	// 前n次PATCH返回409, 模拟偏移量不一致
+	conflictPatches int
+	// PATCH返回204但不接收数据, Upload-Offset保持不变
+	stuckOffset bool
+	patches     int
+}
+
+func newTusServer() (*tusServer, *httptest.Server) {
+	tus := &tusServer{uploads: map[string][]byte{}, lengths: map[string]int64{}}
+	return tus, httptest.NewServer(tus)
+}
+
+func (this *tusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.headers = append(this.headers, r.Header.Clone())
+	switch r.Method {
+	case "POST":
+		this.calls = append(this.calls, "POST "+r.URL.Path)
+		length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		this.metadata = r.Header.Get("Upload-Metadata")
+		path := "/files/" + strconv.Itoa(len(this.uploads)+1)
+		this.uploads[path] = []byte{}
+		this.lengths[path] = length
+		w.Header().Set("Location", path)
+		w.WriteHeader(http.StatusCreated)
+	case "HEAD":
+		this.calls = append(this.calls, "HEAD "+r.URL.Path)
+		data, ok := this.uploads[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(data)))
+		w.Header().Set("Upload-Length", strconv.FormatInt(this.lengths[r.URL.Path], 10))
+		w.WriteHeader(http.StatusOK)
+	case "PATCH":
+		this.calls = append(this.calls, "PATCH "+r.URL.Path+" "+r.Header.Get("Upload-Offset"))
+		this.patches++
+		data, ok := this.uploads[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		offset, err := strconv.Atoi(r.Header.Get("Upload-Offset"))
+		if err != nil || offset != len(data) || this.patches <= this.conflictPatches {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte("offset mismatch"))
+			return
+		}
+		if this.stuckOffset {
+			ioutil.ReadAll(r.Body)
+			w.Header().Set("Upload-Offset", strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if this.patches == this.interruptPatch {
+			// 接收一半数据后断开连接
+			half := make([]byte, r.ContentLength/2)
+			n, _ := io.ReadFull(r.Body, half)
+			this.uploads[r.URL.Path] = append(data, half[:n]...)
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		chunk, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		this.uploads[r.URL.Path] = append(data, chunk...)
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(this.uploads[r.URL.Path])))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (this *tusServer) callList() string {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return strings.Join(this.calls, ", ")
+}
+
+func (this *tusServer) content(path string) []byte {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.uploads[path]
+}
+
+type silentProgress struct{}
+
+func (silentProgress) OnProgress(current uint64, total uint64) {}
+
+func newTestUploadEngine(chunkSize int64) *UploadEngine {
+	engine := NewUploadEngine()
+	engine.SetChunkSize(chunkSize)
+	engine.SetProgressReporter(silentProgress{})
+	return engine
+}
+
+func writeUploadFile(t *testing.T, content []byte) string {
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var uploadContent = []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+func TestUploadEngineCreateAndChunkedPatch(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	engine := newTestUploadEngine(10)
+
+	resp := engine.Visit(server.URL+"/files", nil, 5*time.Second, "", writeUploadFile(t, uploadContent), map[string]string{"filename": "upload.bin"})
+	if resp.Error != nil {
+		t.Fatalf("上传失败: %v", resp.Error)
+	}
+	if resp.UploadURL != server.URL+"/files/1" {
+		t.Errorf("UploadURL = %q", resp.UploadURL)
+	}
+	if resp.Offset != int64(len(uploadContent)) || resp.Size != int64(len(uploadContent)) {
+		t.Errorf("Offset = %d, Size = %d", resp.Offset, resp.Size)
+	}
+	if tus.metadata != "filename dXBsb2FkLmJpbg==" {
+		t.Errorf("Upload-Metadata = %q", tus.metadata)
+	}
+	want := "POST /files, PATCH /files/1 0, PATCH /files/1 10, PATCH /files/1 20, PATCH /files/1 30"
+	if calls := tus.callList(); calls != want {
+		t.Errorf("请求顺序 = %s, 期望 %s", calls, want)
+	}
+	if !bytes.Equal(tus.content("/files/1"), uploadContent) {
+		t.Errorf("服务端内容 = %q", tus.content("/files/1"))
+	}
+}
+
+func TestUploadEngineResumeAfterInterruptedPatch(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	tus.interruptPatch = 2
+	engine := newTestUploadEngine(10)
+
+	resp := engine.Visit(server.URL+"/files", nil, 5*time.Second, "", writeUploadFile(t, uploadContent), nil)
+	if resp.Error != nil {
+		t.Fatalf("上传失败: %v", resp.Error)
+	}
+	// 第二个分片只接收了5字节, 通过HEAD查询后从15继续上传
+	want := "POST /files, PATCH /files/1 0, PATCH /files/1 10, HEAD /files/1, PATCH /files/1 15, PATCH /files/1 25, PATCH /files/1 35"
+	if calls := tus.callList(); calls != want {
+		t.Errorf("请求顺序 = %s, 期望 %s", calls, want)
+	}
+	if !bytes.Equal(tus.content("/files/1"), uploadContent) {
+		t.Errorf("服务端内容 = %q", tus.content("/files/1"))
+	}
+}
+
+func TestUploadEngineOffsetConflict(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	tus.conflictPatches = 1
+	engine := newTestUploadEngine(100)
+
+	resp := engine.Visit(server.URL+"/files", nil, 5*time.Second, "", writeUploadFile(t, uploadContent), nil)
+	if resp.Error != nil {
+		t.Fatalf("上传失败: %v", resp.Error)
+	}
+	want := "POST /files, PATCH /files/1 0, HEAD /files/1, PATCH /files/1 0"
+	if calls := tus.callList(); calls != want {
+		t.Errorf("请求顺序 = %s, 期望 %s", calls, want)
+	}
+	if !bytes.Equal(tus.content("/files/1"), uploadContent) {
+		t.Errorf("服务端内容 = %q", tus.content("/files/1"))
+	}
+}
+
+func TestUploadEngineOffsetConflictExhausted(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	tus.conflictPatches = 100
+	engine := newTestUploadEngine(100)
+	engine.SetMaxResume(2)
+
+	resp := engine.Visit(server.URL+"/files", nil, 5*time.Second, "", writeUploadFile(t, uploadContent), nil)
+	var statusErr *HTTPStatusError
+	if !errors.As(resp.Error, &statusErr) || statusErr.StatusCode != http.StatusConflict {
+		t.Fatalf("Error = %v, 期望409状态码错误", resp.Error)
+	}
+	if resp.Status || resp.StatusCode != http.StatusConflict {
+		t.Errorf("Status = %v, StatusCode = %d", resp.Status, resp.StatusCode)
+	}
+	if resp.UploadURL != server.URL+"/files/1" {
+		t.Errorf("UploadURL = %q, 失败后应保留上传地址", resp.UploadURL)
+	}
+}
+
+func TestUploadEngineStuckOffset(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	tus.stuckOffset = true
+	engine := newTestUploadEngine(10)
+	engine.SetMaxResume(2)
+
+	resp := engine.Visit(server.URL+"/files", nil, 5*time.Second, "", writeUploadFile(t, uploadContent), nil)
+	if resp.Error == nil || !strings.Contains(resp.Error.Error(), "Upload-Offset无效") {
+		t.Fatalf("Error = %v, 期望偏移量无效的错误", resp.Error)
+	}
+	// 偏移量不前进的PATCH计入续传次数, 超过后停止上传
+	want := "POST /files, PATCH /files/1 0, HEAD /files/1, PATCH /files/1 0, HEAD /files/1, PATCH /files/1 0"
+	if calls := tus.callList(); calls != want {
+		t.Errorf("请求顺序 = %s, 期望 %s", calls, want)
+	}
+	if resp.Offset != 0 {
+		t.Errorf("Offset = %d, 期望0", resp.Offset)
+	}
+}
+
+func TestUploadEngineResume(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	tus.uploads["/files/abc"] = append([]byte{}, uploadContent[:12]...)
+	tus.lengths["/files/abc"] = int64(len(uploadContent))
+	engine := newTestUploadEngine(10)
+
+	resp := engine.Resume(server.URL+"/files/abc", nil, 5*time.Second, "", writeUploadFile(t, uploadContent))
+	if resp.Error != nil {
+		t.Fatalf("续传失败: %v", resp.Error)
+	}
+	want := "HEAD /files/abc, PATCH /files/abc 12, PATCH /files/abc 22, PATCH /files/abc 32"
+	if calls := tus.callList(); calls != want {
+		t.Errorf("请求顺序 = %s, 期望 %s", calls, want)
+	}
+	if !bytes.Equal(tus.content("/files/abc"), uploadContent) {
+		t.Errorf("服务端内容 = %q", tus.content("/files/abc"))
+	}
+}
+
+func TestUploadEngineRequestOptions(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	engine := newTestUploadEngine(10)
+
+	resp := engine.Visit(server.URL+"/files", nil, 5*time.Second, "", writeUploadFile(t, uploadContent), nil,
+		WithRequestID("upload-1"), WithAuth(NewBearerAuth("token")))
+	if resp.Error != nil {
+		t.Fatalf("上传失败: %v", resp.Error)
+	}
+	if resp.RequestID != "upload-1" || resp.Request == nil || resp.Request.ID != "upload-1" {
+		t.Errorf("RequestID = %q, Request = %v", resp.RequestID, resp.Request)
+	}
+	// 每次tus请求都携带请求ID和认证信息
+	for i, header := range tus.headers {
+		if header.Get("X-Request-ID") != "upload-1" || header.Get("Authorization") != "Bearer token" {
+			t.Errorf("第%d次请求的请求头 = %v", i+1, header)
+		}
+	}
+	if resp.Timings.Connect <= 0 || resp.Timings.FirstByte <= 0 || resp.Timings.Total < resp.Timings.FirstByte {
+		t.Errorf("Timings = %+v", resp.Timings)
+	}
+}
+
+func TestUploadEngineRequestIDHeader(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	engine := newTestUploadEngine(100)
+
+	// 调用方在请求头中设置的请求ID优先于WithRequestID, 与实际发送的一致
+	resp := engine.Visit(server.URL+"/files", map[string]string{"x-request-id": "caller-1"}, 5*time.Second, "", writeUploadFile(t, uploadContent), nil,
+		WithRequestID("upload-1"))
+	if resp.Error != nil {
+		t.Fatalf("上传失败: %v", resp.Error)
+	}
+	if resp.RequestID != "caller-1" {
+		t.Errorf("RequestID = %q, 期望caller-1", resp.RequestID)
+	}
+	for i, header := range tus.headers {
+		if header.Get("X-Request-ID") != "caller-1" {
+			t.Errorf("第%d次请求的X-Request-ID = %q", i+1, header.Get("X-Request-ID"))
+		}
+	}
+}
+
+func TestUploadEngineStatusCheck(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	tus.conflictPatches = 100
+	engine := newTestUploadEngine(100)
+	engine.SetMaxResume(0)
+	engine.SetMaxBodySize(4)
+
+	// 状态码校验对每次tus请求生效, 校验失败时保留截断后的响应内容
+	resp := engine.Visit(server.URL+"/files", nil, 5*time.Second, "", writeUploadFile(t, uploadContent), nil,
+		WithErrorStatus(StatusRange{From: 400, To: 499}))
+	var statusErr *HTTPStatusError
+	if !errors.As(resp.Error, &statusErr) || statusErr.StatusCode != http.StatusConflict || statusErr.RequestID != resp.RequestID {
+		t.Fatalf("Error = %v, 期望409状态码错误", resp.Error)
+	}
+	if string(statusErr.Body) != "offs" {
+		t.Errorf("Body = %q, 期望截断为4字节", statusErr.Body)
+	}
+}
+
+func TestUploadEngineRetry(t *testing.T) {
+	tus, server := newTusServer()
+	defer server.Close()
+	tus.conflictPatches = 1
+	engine := newTestUploadEngine(100)
+	engine.SetMaxResume(0)
+
+	resp := engine.Visit(server.URL+"/files", nil, 5*time.Second, "", writeUploadFile(t, uploadContent), nil)
+	if resp.Error == nil {
+		t.Fatal("第一次PATCH返回409时应上传失败")
+	}
+	// 重试沿用已创建的上传地址, 不再重新创建上传任务
+	retried := resp.Request.Retry()
+	if retried.Error != nil {
+		t.Fatalf("重试失败: %v", retried.Error)
+	}
+	if retried.RequestID != resp.RequestID || retried.Request.RetryNumber != 1 {
+		t.Errorf("RequestID = %q, RetryNumber = %d", retried.RequestID, retried.Request.RetryNumber)
+	}
+	want := "POST /files, PATCH /files/1 0, HEAD /files/1, PATCH /files/1 0"
+	if calls := tus.callList(); calls != want {
+		t.Errorf("请求顺序 = %s, 期望 %s", calls, want)
+	}
+	if !bytes.Equal(tus.content("/files/1"), uploadContent) {
+		t.Errorf("服务端内容 = %q", tus.content("/files/1"))
+	}
+}
+
+func TestUploadEngineMissingFile(t *testing.T) {
+	engine := newTestUploadEngine(10)
+	resp := engine.Visit("http://127.0.0.1:1/files", nil, time.Second, "", filepath.Join(t.TempDir(), "missing.bin"), nil)
+	if !errors.Is(resp.Error, os.ErrNotExist) {
+		t.Errorf("Error = %v, 期望文件不存在", resp.Error)
+	}
+}
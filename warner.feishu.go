@@ -1,10 +1,7 @@
 package gogorequest
 
 import (
-	"crypto/hmac"
 	"crypto/sha1"
-	"crypto/sha256"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -23,13 +20,8 @@ type warnerFeiShu struct {
 
 func (this *warnerFeiShu) __createSign(timestamp int64) (string, error) {
 	stringToSign := fmt.Sprintf("%v", timestamp) + "\n" + this.token
-	var data []byte
-	h := hmac.New(sha256.New, []byte(stringToSign))
-	_, err := h.Write(data)
-	if err != nil {
-		return "", err
-	}
-	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	// 飞书以拼接的字符串作为密钥, 对空数据计算HMAC-SHA256
+	signature := hmacSign(SignSHA256, SignBase64, []byte(stringToSign), nil)
 	return signature, nil
 }
 